@@ -3,8 +3,9 @@ module github.com/aokhrimenko/gpsd-simulator
 go 1.24.1
 
 require (
-	github.com/Masterminds/semver/v3 v3.3.1 // indirect
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.9.1 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
+	github.com/Masterminds/semver/v3 v3.3.1
+	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
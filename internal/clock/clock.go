@@ -0,0 +1,208 @@
+package clock
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Jump moves the simulated clock by Offset, forwards or backwards, when it reaches At
+type Jump struct {
+	At     time.Time
+	Offset time.Duration
+}
+
+// ParseJump parses "<at>=<offset>", where at is an RFC3339 instant or a duration since the clock start
+// and offset is a signed duration, e.g. "2026-03-29T00:59:50Z=1h" or "10m=-30s"
+func ParseJump(value string, start time.Time) (Jump, error) {
+	at, offset, ok := strings.Cut(value, "=")
+	if !ok {
+		return Jump{}, fmt.Errorf("invalid clock jump %q, expected <at>=<offset>", value)
+	}
+	var jump Jump
+	var err error
+	if jump.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+		sinceStart, durationErr := time.ParseDuration(at)
+		if durationErr != nil {
+			return Jump{}, fmt.Errorf("invalid clock jump time %q, expected an RFC3339 time or a duration", at)
+		}
+		jump.At = start.Add(sinceStart)
+	}
+	if jump.Offset, err = time.ParseDuration(offset); err != nil {
+		return Jump{}, fmt.Errorf("invalid clock jump offset %q: %w", offset, err)
+	}
+	return jump, nil
+}
+
+// DefaultVirtualStart is the start of the virtual clock without the configured start, so the runs are repeatable
+var DefaultVirtualStart = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type Config struct {
+	// Start is the simulated time at the clock start, the wall clock when zero
+	Start time.Time
+	// Offset is added to the start
+	Offset time.Duration
+	// Scale is how many times faster than the wall clock the simulated time runs, 1 when zero
+	Scale float64
+	Jumps []Jump
+	// LeapSeconds are the instants of the leap seconds inserted in addition to the known ones
+	LeapSeconds []time.Time
+}
+
+// SimClock is the simulated time, which starts at an arbitrary instant, runs the scale faster than the
+// wall clock and applies the scripted jumps. It's anchored again on every scale change or jump, so the
+// simulated time only changes by the jumps. The wall clock of a virtual or a held clock moves only by Advance.
+type SimClock struct {
+	mu          sync.Mutex
+	virtual     bool
+	held        bool
+	virtualWall time.Time
+	wallAnchor  time.Time
+	simAnchor   time.Time
+	scale       float64
+	jumps       []Jump
+	leapSeconds []time.Time
+}
+
+func New(config Config) *SimClock {
+	return newClock(config, false)
+}
+
+// NewVirtual creates the clock with the virtual wall clock starting at the configured start
+func NewVirtual(config Config) *SimClock {
+	if config.Start.IsZero() {
+		config.Start = DefaultVirtualStart
+	}
+	return newClock(config, true)
+}
+
+func newClock(config Config, virtual bool) *SimClock {
+	c := &SimClock{
+		virtual:     virtual,
+		virtualWall: config.Start,
+		scale:       config.Scale,
+		jumps:       slices.Clone(config.Jumps),
+		leapSeconds: slices.Clone(config.LeapSeconds),
+	}
+	if c.scale == 0 {
+		c.scale = 1
+	}
+	c.wallAnchor = c.wall()
+	c.simAnchor = config.Start
+	if c.simAnchor.IsZero() {
+		c.simAnchor = c.wallAnchor
+	}
+	c.simAnchor = c.simAnchor.Add(config.Offset)
+	slices.SortFunc(c.jumps, func(a, b Jump) int {
+		return a.At.Compare(b.At)
+	})
+	// the jumps before the start have already happened
+	for len(c.jumps) > 0 && c.jumps[0].At.Before(c.simAnchor) {
+		c.jumps = c.jumps[1:]
+	}
+	slices.SortFunc(c.leapSeconds, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+	return c
+}
+
+func (c *SimClock) wall() time.Time {
+	if c.virtual || c.held {
+		return c.virtualWall
+	}
+	return time.Now()
+}
+
+// Wall returns the wall clock time, the system time or the virtual one
+func (c *SimClock) Wall() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.wall()
+}
+
+// Virtual tells whether the wall clock is virtual
+func (c *SimClock) Virtual() bool {
+	return c.virtual
+}
+
+// Hold stops the system wall clock, so the simulated time moves only by Advance until Release. It does nothing
+// for the virtual clock.
+func (c *SimClock) Hold() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.virtual || c.held {
+		return
+	}
+	c.virtualWall = time.Now()
+	c.held = true
+}
+
+// Release lets the held clock follow the system wall clock again from the simulated time it was advanced to
+func (c *SimClock) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.held {
+		return
+	}
+	c.simAnchor = c.now(c.virtualWall)
+	c.held = false
+	c.wallAnchor = time.Now()
+}
+
+// Advance moves the virtual or the held wall clock, it does nothing for the running system one
+func (c *SimClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.virtualWall = c.virtualWall.Add(d)
+}
+
+// Now returns the simulated time
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now(c.wall())
+}
+
+func (c *SimClock) now(wall time.Time) time.Time {
+	for {
+		now := c.simAnchor.Add(time.Duration(float64(wall.Sub(c.wallAnchor)) * c.scale))
+		if len(c.jumps) == 0 || now.Before(c.jumps[0].At) {
+			return now
+		}
+		// anchor at the moment the jump happened, every jump happens once even if it goes backwards
+		jump := c.jumps[0]
+		c.jumps = c.jumps[1:]
+		c.wallAnchor = c.wallAnchor.Add(time.Duration(float64(jump.At.Sub(c.simAnchor)) / c.scale))
+		c.simAnchor = jump.At.Add(jump.Offset)
+	}
+}
+
+func (c *SimClock) Scale() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.scale
+}
+
+func (c *SimClock) SetScale(scale float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wall := c.wall()
+	c.simAnchor = c.now(wall)
+	c.wallAnchor = wall
+	c.scale = scale
+}
+
+// LeapSeconds returns the GPS-UTC offset at the time, with the leap seconds inserted by the config
+func (c *SimClock) LeapSeconds(t time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	leapSeconds := LeapSeconds(t)
+	for _, inserted := range c.leapSeconds {
+		if !t.Before(inserted) {
+			leapSeconds++
+		}
+	}
+	return leapSeconds
+}
@@ -0,0 +1,75 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHoldAdvanceRelease(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	c := New(Config{Start: start, Scale: 2})
+
+	c.Hold()
+	held := c.Now()
+	time.Sleep(20 * time.Millisecond)
+	if now := c.Now(); !now.Equal(held) {
+		t.Fatalf("held clock moved from %v to %v", held, now)
+	}
+	for range 10 {
+		c.Advance(time.Second)
+	}
+	if got, want := c.Now(), held.Add(20*time.Second); !got.Equal(want) {
+		t.Fatalf("got %v after advancing, want %v", got, want)
+	}
+
+	c.Release()
+	released := c.Now()
+	if released.Before(held.Add(20*time.Second)) || released.After(held.Add(21*time.Second)) {
+		t.Fatalf("released clock at %v, want it to continue from %v", released, held.Add(20*time.Second))
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !c.Now().After(released) {
+		t.Fatal("released clock doesn't follow the wall clock")
+	}
+}
+
+func TestVirtualClock(t *testing.T) {
+	c := NewVirtual(Config{})
+	if !c.Now().Equal(DefaultVirtualStart) {
+		t.Fatalf("got %v, want %v", c.Now(), DefaultVirtualStart)
+	}
+	c.Hold()
+	c.Advance(time.Minute)
+	c.Release()
+	if got, want := c.Now(), DefaultVirtualStart.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := c.Wall(), DefaultVirtualStart.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("got wall %v, want %v", got, want)
+	}
+}
+
+func TestParseJump(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  Jump
+		err   bool
+	}{
+		{"2026-03-29T00:59:50Z=1h", Jump{At: time.Date(2026, time.March, 29, 0, 59, 50, 0, time.UTC), Offset: time.Hour}, false},
+		{"10m=-30s", Jump{At: start.Add(10 * time.Minute), Offset: -30 * time.Second}, false},
+		{"10m", Jump{}, true},
+		{"never=1h", Jump{}, true},
+		{"10m=later", Jump{}, true},
+	}
+	for _, test := range tests {
+		jump, err := ParseJump(test.value, start)
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v", test.value, err)
+			continue
+		}
+		if !jump.At.Equal(test.want.At) || jump.Offset != test.want.Offset {
+			t.Errorf("%s: got %+v, want %+v", test.value, jump, test.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+)
+
+type exportConfig struct {
+	Debug      bool
+	Verbose    bool
+	InputFile  string
+	OutputFile string
+}
+
+func Export(currentVersion string) *cobra.Command {
+	exportCfg := &exportConfig{}
+	var rootCmd = &cobra.Command{
+		Use:     "export",
+		Version: currentVersion,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeExportCommand(currentVersion, exportCfg)
+		},
+	}
+	rootCmd.Flags().StringVarP(&exportCfg.InputFile, "input", "i", "", "Path to the input gpsd route file")
+	rootCmd.Flags().StringVarP(&exportCfg.OutputFile, "output", "o", "", "Path to the output file, the format is chosen by the extension: .gpx, .kml, .kmz or .json")
+	rootCmd.Flags().BoolVarP(&exportCfg.Debug, "debug", "d", false, "Enable debug logging")
+	rootCmd.Flags().BoolVarP(&exportCfg.Verbose, "verbose", "v", false, "Enable verbose logging")
+
+	rootCmd.Flags().SortFlags = false
+	_ = rootCmd.MarkFlagRequired("input")
+	_ = rootCmd.MarkFlagRequired("output")
+	return rootCmd
+}
+
+func executeExportCommand(currentVersionString string, cfg *exportConfig) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logLevel := logger.LevelInfo
+	if cfg.Verbose {
+		logLevel = logger.LevelVerbose
+	} else if cfg.Debug {
+		logLevel = logger.LevelDebug
+	}
+
+	log := logger.NewStdoutLogger(logLevel)
+	currentVersion, err := semver.NewVersion(currentVersionString)
+	if err != nil {
+		log.Fatal(err)
+		return err
+	}
+
+	log.Infof("GPSD Simulator v%s", currentVersion.String())
+
+	// the update rate doesn't matter for the export
+	routeCtrl := route.NewController(ctx, time.Second, log)
+	defer routeCtrl.Shutdown()
+
+	if err = routeCtrl.Export(cfg.InputFile, cfg.OutputFile); err != nil {
+		log.Error("Failed to export route:", err)
+	}
+
+	return nil
+}
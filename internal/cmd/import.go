@@ -2,7 +2,7 @@ package cmd
 
 import (
 	"context"
-	"time"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/cobra"
@@ -17,8 +17,13 @@ type importConfig struct {
 	Verbose    bool
 	Name       string
 	InputFile  string
+	Feature    string
 	OutputFile string
 	Speed      uint
+	Receiver   route.Receiver
+	Dynamics   route.Dynamics
+	Vehicle    string
+	Rate       string
 }
 
 func Import(currentVersion string) *cobra.Command {
@@ -32,9 +37,17 @@ func Import(currentVersion string) *cobra.Command {
 		},
 	}
 	rootCmd.Flags().StringVarP(&importCfg.Name, "name", "n", "", "Route name")
-	rootCmd.Flags().StringVarP(&importCfg.InputFile, "input", "i", "", "Path to the input GeoJSON file")
+	rootCmd.Flags().StringVarP(&importCfg.InputFile, "input", "i", "", "Path to the input GeoJSON, GPX, KML, KMZ or NMEA file")
+	rootCmd.Flags().StringVar(&importCfg.Feature, "feature", "", "Feature of the GeoJSON file to import, by its index from 0 or by its name (default is the first one with a line)")
 	rootCmd.Flags().StringVarP(&importCfg.OutputFile, "output", "o", "", "Path to the output gpsd route file")
 	rootCmd.Flags().UintVarP(&importCfg.Speed, "speed", "s", 0, "Speed in km/h for the route (default is 0, which means no speed limit)")
+	rootCmd.Flags().StringVar(&importCfg.Vehicle, "vehicle", "", "Vehicle profile providing the speed, dynamics, noise and altitude of the route: "+strings.Join(route.VehicleNames(), ", "))
+	rootCmd.Flags().Float64Var(&importCfg.Dynamics.MaxAcceleration, "max-acceleration", 0, "Maximum acceleration in m/s², the route starts from standstill (default is 0, which means no limit)")
+	rootCmd.Flags().Float64Var(&importCfg.Dynamics.MaxDeceleration, "max-deceleration", 0, "Maximum deceleration in m/s², the route ends with a standstill (default is 0, which means no limit)")
+	rootCmd.Flags().Float64Var(&importCfg.Dynamics.MaxLateralAcceleration, "max-lateral-acceleration", 0, "Maximum lateral acceleration in m/s² limiting the speed in the turns (default is 0, which means no limit)")
+	rootCmd.Flags().StringVar(&importCfg.Rate, "rate", "1Hz", "Update rate of the route, a frequency like 10Hz or a period like 200ms")
+	rootCmd.Flags().Float64Var(&importCfg.Receiver.GeoidSeparation, "geoid-separation", 0, "Height of the geoid above the WGS84 ellipsoid in meters stored in the route")
+	rootCmd.Flags().Float64Var(&importCfg.Receiver.MagneticVariation, "magvar", 0, "Magnetic variation in degrees stored in the route, east is positive")
 	rootCmd.Flags().BoolVarP(&importCfg.Debug, "debug", "d", false, "Enable debug logging")
 	rootCmd.Flags().BoolVarP(&importCfg.Verbose, "verbose", "v", false, "Enable verbose logging")
 
@@ -64,10 +77,29 @@ func executeImportCommand(currentVersionString string, cfg *importConfig, writer
 
 	log.Infof("GPSD Simulator v%s", currentVersion.String())
 
-	routeCtrl := route.NewController(ctx, time.Second, log)
+	stepDelay, err := route.ParseRate(cfg.Rate)
+	if err != nil {
+		log.Fatal(err)
+		return err
+	}
+	if err = cfg.Dynamics.Validate(); err != nil {
+		log.Fatal(err)
+		return err
+	}
+	if cfg.Vehicle != "" {
+		if _, err = route.LookupVehicle(cfg.Vehicle); err != nil {
+			log.Fatal(err)
+			return err
+		}
+	}
+	routeCtrl := route.NewController(ctx, stepDelay, log)
 	defer routeCtrl.Shutdown()
 
-	err = routeCtrl.Import(cfg.Name, cfg.InputFile, cfg.OutputFile, cfg.Speed)
+	var receiver *route.Receiver
+	if cfg.Receiver != (route.Receiver{}) {
+		receiver = &cfg.Receiver
+	}
+	err = routeCtrl.Import(cfg.Name, cfg.InputFile, cfg.Feature, cfg.OutputFile, cfg.Vehicle, cfg.Speed, cfg.Dynamics, receiver)
 	if err != nil {
 		log.Error("Failed to import route:", err)
 	}
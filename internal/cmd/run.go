@@ -2,46 +2,100 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/cobra"
 
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
 	"github.com/aokhrimenko/gpsd-simulator/internal/gpsd"
 	"github.com/aokhrimenko/gpsd-simulator/internal/http"
 	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+	"github.com/aokhrimenko/gpsd-simulator/internal/nmea"
+	"github.com/aokhrimenko/gpsd-simulator/internal/pty"
 	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
 	"github.com/aokhrimenko/gpsd-simulator/internal/version"
 )
 
 type mainConfig struct {
-	GpsdPort  uint
-	WebUiPort uint
-	Debug     bool
-	Verbose   bool
-	File      string
+	GpsdPort          uint
+	WebUiPort         uint
+	Debug             bool
+	Verbose           bool
+	File              string
+	Pty               bool
+	PtyLink           string
+	DevicePathChanged bool
+	Almanac           string
+	GpsdProfile       string
+	Outages           []string
+	Rate              string
+	Vehicle           string
+	End               string
+	TimeScale         float64
+	ClockStart        string
+	ClockOffset       time.Duration
+	ClockJumps        []string
+	LeapSeconds       []string
+	Headless          bool
+	Stepping          bool
+	Stdin             bool
+	Replay            string
+	ReplayRebase      bool
 }
 
 func Run(currentVersion string) *cobra.Command {
 	mainCfg := &mainConfig{}
 	writerCfg := gpsd.WriterConfig{}
+	nmeaCfg := nmea.Config{}
+	skyCfg := sky.SimulatedConfig{}
+	receiver := route.Receiver{}
+	noise := route.Noise{}
 	var runCmd = &cobra.Command{
 		Use:     "run",
 		Version: currentVersion,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return executeRunCommand(currentVersion, mainCfg, writerCfg)
+			mainCfg.DevicePathChanged = cmd.Flags().Changed("device-path")
+			if err := applyGpsdProfile(cmd, mainCfg.GpsdProfile, &writerCfg); err != nil {
+				return err
+			}
+			if err := applyVehicle(cmd, mainCfg.Vehicle, &writerCfg); err != nil {
+				return err
+			}
+			return executeRunCommand(currentVersion, mainCfg, writerCfg, nmeaCfg, skyCfg, receiver, noise)
 		},
 	}
 	runCmd.Flags().UintVarP(&mainCfg.GpsdPort, "gpsd-port", "g", 2947, "Port for the GPSD server")
 	runCmd.Flags().UintVarP(&mainCfg.WebUiPort, "webui-port", "w", 8881, "Port for the web UI")
 	runCmd.Flags().BoolVarP(&mainCfg.Debug, "debug", "d", false, "Enable debug logging")
 	runCmd.Flags().BoolVarP(&mainCfg.Verbose, "verbose", "v", false, "Enable verbose logging")
-	runCmd.Flags().StringVarP(&mainCfg.File, "file", "f", "", "Path to the route file (JSON format)")
+	runCmd.Flags().StringVarP(&mainCfg.File, "file", "f", "", "Path to the route file (JSON format), or a GPX, KML, KMZ, GeoJSON (.geojson) or NMEA (.nmea, .nme) track")
+	runCmd.Flags().BoolVar(&mainCfg.Headless, "headless", false, "Run on a virtual clock without the web UI: the points are sent as fast as the clients read them, requires --file")
+	runCmd.Flags().BoolVar(&mainCfg.Stepping, "stepping", false, "Start in the stepping mode, the points are sent only when they are requested with POST /route/step, ?STEP or the step command")
+	runCmd.Flags().BoolVar(&mainCfg.Stdin, "stdin", false, "Read the route commands from stdin, one per line: step [count], run or pause")
+	runCmd.Flags().StringVar(&mainCfg.Rate, "rate", "1Hz", "Update rate, a frequency like 10Hz or a period like 200ms")
+	runCmd.Flags().StringVar(&mainCfg.Vehicle, "vehicle", "", "Vehicle profile for the new routes, also limits the TPV fields unless --tpv-fields is set: "+strings.Join(route.VehicleNames(), ", "))
+	runCmd.Flags().StringVar(&mainCfg.End, "end", route.EndLoop, "Behavior at the end of the route, the route file value takes precedence: "+strings.Join(route.EndBehaviors, ", "))
+	runCmd.Flags().StringVar(&mainCfg.Replay, "replay", "", "Path to a gpsd JSON log (gpspipe -w output) the gpsd server replays to its clients instead of the simulated reports")
+	runCmd.Flags().BoolVar(&mainCfg.ReplayRebase, "replay-rebase", false, "Move the times of the replayed reports, so the log starts when the client starts watching")
+	runCmd.Flags().Float64Var(&mainCfg.TimeScale, "time-scale", 1, "How many times faster than the wall clock the simulation runs, 0.1 - 100")
+	runCmd.Flags().StringVar(&mainCfg.ClockStart, "clock-start", "", "Simulated time at the start in RFC3339, e.g. 2026-12-31T23:59:30Z (default is the system time)")
+	runCmd.Flags().DurationVar(&mainCfg.ClockOffset, "clock-offset", 0, "Offset of the simulated time from the start, e.g. -1h")
+	runCmd.Flags().StringArrayVar(&mainCfg.ClockJumps, "clock-jump", nil, "Move the simulated time by an offset when it reaches an RFC3339 time or a duration since the start: <at>=<offset>, e.g. 10m=-30s")
+	runCmd.Flags().StringArrayVar(&mainCfg.LeapSeconds, "leap-second", nil, "RFC3339 time from which the GPS-UTC offset grows by one second, in addition to the known leap seconds")
+	runCmd.Flags().StringArrayVar(&mainCfg.Outages, "outage", nil, "Fix outage applied to every route: distance:<from>-<to>[:<mode>] in meters or time:<from>-<to>[:<mode>] in seconds, mode 1 is no fix (default), 2 is 2D fix")
+	runCmd.Flags().BoolVar(&mainCfg.Pty, "pty", false, "Stream NMEA sentences to a pseudo-terminal (Linux only)")
+	runCmd.Flags().StringVar(&mainCfg.PtyLink, "pty-link", "", "Create a symlink with this path to the pseudo-terminal, implies --pty")
 
 	// WriterConfig
+	runCmd.Flags().StringVar(&mainCfg.GpsdProfile, "gpsd-profile", "", "Emulate the output of a gpsd release (3.17 - 3.25), the flags below take precedence over it")
 	runCmd.Flags().StringVar(&writerCfg.VersionRelease, "version-release", gpsd.DefaultVersionRelease, "VERSION/release field")
 	runCmd.Flags().StringVar(&writerCfg.VersionRev, "version-revision", gpsd.DefaultVersionRev, "VERSION/rev field")
 	runCmd.Flags().UintVar(&writerCfg.VersionProtoMajor, "version-proto-major", gpsd.DefaultVersionProtoMajor, "VERSION/proto_major field")
@@ -53,12 +107,43 @@ func Run(currentVersion string) *cobra.Command {
 	runCmd.Flags().StringVar(&writerCfg.DeviceParity, "device-parity", gpsd.DefaultDeviceParity, "DEVICES/devices/parity field")
 	runCmd.Flags().UintVar(&writerCfg.DeviceStopBits, "device-stop-bits", gpsd.DefaultDeviceStopBits, "DEVICES/devices/stopbits field")
 	runCmd.Flags().UintVar(&writerCfg.TpvMode, "tpv-mode", gpsd.DefaultTpvMode, "TPV/mode field")
+	runCmd.Flags().UintVar(&writerCfg.TpvStatus, "tpv-status", gpsd.DefaultTpvStatus, "TPV/status field")
+	runCmd.Flags().StringSliceVar(&writerCfg.TpvFields, "tpv-fields", gpsd.TpvFields, "Optional TPV fields to report")
+	runCmd.Flags().Float64Var(&writerCfg.Uere, "tpv-uere", gpsd.DefaultUere, "User equivalent range error in meters, TPV error estimates are the DOPs multiplied by it")
+	runCmd.Flags().StringSliceVar(&writerCfg.SkyFields, "sky-fields", gpsd.SkyFields, "Optional SKY fields to report")
+	runCmd.Flags().StringSliceVar(&writerCfg.DeviceFields, "device-fields", gpsd.DeviceFields, "Optional DEVICE fields to report")
+	runCmd.Flags().UintVar(&writerCfg.SkyInterval, "sky-interval", gpsd.DefaultSkyInterval, "Send SKY report after every N TPV reports, 0 disables SKY reports")
+
+	// Receiver, the route file values take precedence
+	runCmd.Flags().Float64Var(&receiver.GeoidSeparation, "geoid-separation", 0, "Height of the geoid above the WGS84 ellipsoid in meters, TPV/geoidSep field")
+	runCmd.Flags().Float64Var(&receiver.MagneticVariation, "magvar", 0, "Magnetic variation in degrees, east is positive, TPV/magvar field")
+
+	// Position noise, the route file values take precedence
+	runCmd.Flags().StringVar(&noise.Model, "noise-model", route.NoiseModelNone, "Position noise model: none, white, gauss-markov or multipath")
+	runCmd.Flags().Float64Var(&noise.Sigma, "noise-sigma", 0, "Standard deviation of the horizontal position noise in meters")
+	runCmd.Flags().Int64Var(&noise.Seed, "noise-seed", 1, "Seed of the position noise")
+	runCmd.Flags().Float64Var(&noise.CorrelationTime, "noise-correlation-time", route.DefaultNoiseCorrelationTime, "Correlation time of the gauss-markov noise in seconds")
+	runCmd.Flags().Float64Var(&noise.MultipathProbability, "noise-multipath-probability", route.DefaultNoiseMultipathProbability, "Probability of a multipath jump on every point")
+	runCmd.Flags().Float64Var(&noise.MultipathJump, "noise-multipath-jump", route.DefaultNoiseMultipathJump, "Size of a multipath jump in meters")
+
+	// Simulated satellites
+	runCmd.Flags().UintVar(&skyCfg.GPS, "sky-gps", sky.DefaultGPS, "Number of visible GPS satellites")
+	runCmd.Flags().UintVar(&skyCfg.GLONASS, "sky-glonass", sky.DefaultGLONASS, "Number of visible GLONASS satellites")
+	runCmd.Flags().UintVar(&skyCfg.Galileo, "sky-galileo", sky.DefaultGalileo, "Number of visible Galileo satellites")
+	runCmd.Flags().UintVar(&skyCfg.BeiDou, "sky-beidou", sky.DefaultBeiDou, "Number of visible BeiDou satellites")
+	runCmd.Flags().Float64Var(&skyCfg.ElevationMask, "sky-elevation-mask", sky.DefaultElevationMask, "Satellites below this elevation in degrees aren't used in the fix")
+	runCmd.Flags().Int64Var(&skyCfg.Seed, "sky-seed", sky.DefaultSeed, "Seed of the simulated satellites constellation")
+	runCmd.Flags().StringVar(&mainCfg.Almanac, "sky-almanac", "", "Path to a YUMA/SEM almanac or TLE file to compute the satellites positions from, instead of the simulated constellation")
+
+	// NMEA
+	runCmd.Flags().StringVar(&nmeaCfg.Talker, "nmea-talker", nmea.DefaultTalker, "NMEA talker ID (GP, GN or GL)")
+	runCmd.Flags().StringSliceVar(&nmeaCfg.Sentences, "nmea-sentences", nmea.DefaultSentences, "NMEA sentences to generate for each point")
 
 	runCmd.Flags().SortFlags = false
 	return runCmd
 }
 
-func executeRunCommand(currentVersionString string, mainCfg *mainConfig, writerCfg gpsd.WriterConfig) error {
+func executeRunCommand(currentVersionString string, mainCfg *mainConfig, writerCfg gpsd.WriterConfig, nmeaCfg nmea.Config, skyCfg sky.SimulatedConfig, receiver route.Receiver, noise route.Noise) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	_ = ctx
@@ -78,47 +163,256 @@ func executeRunCommand(currentVersionString string, mainCfg *mainConfig, writerC
 	}
 
 	log.Infof("GPSD Simulator v%s", currentVersion.String())
+	if mainCfg.Headless && mainCfg.File == "" {
+		err = fmt.Errorf("--headless requires the route --file")
+		log.Fatal(err)
+		return err
+	}
+
+	if mainCfg.Headless && mainCfg.Replay != "" {
+		err = fmt.Errorf("--replay runs on the wall clock and can't be used with --headless")
+		log.Fatal(err)
+		return err
+	}
+
+	// the pseudo-terminal drops what its reader doesn't take, so it can't pace the virtual clock
+	if mainCfg.Headless && (mainCfg.Pty || mainCfg.PtyLink != "") {
+		err = fmt.Errorf("--pty doesn't block on a slow reader and can't be used with --headless")
+		log.Fatal(err)
+		return err
+	}
 
 	signalCtx, signalCancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer signalCancel()
 
 	go version.CheckForUpdate(ctx, log, currentVersion)
 
-	routeCtrl := route.NewController(ctx, time.Second, log)
+	stepDelay, err := route.ParseRate(mainCfg.Rate)
+	if err != nil {
+		log.Fatal(err)
+		return err
+	}
+	routeCtrl := route.NewController(ctx, stepDelay, log)
+	routeCtrl.SetReceiver(receiver)
+	if mainCfg.Vehicle != "" {
+		vehicle, err := route.LookupVehicle(mainCfg.Vehicle)
+		if err != nil {
+			log.Fatal(err)
+			return err
+		}
+		routeCtrl.SetVehicle(vehicle)
+	}
+	outages := make([]route.Outage, 0, len(mainCfg.Outages))
+	for _, value := range mainCfg.Outages {
+		outage, err := route.ParseOutage(value)
+		if err != nil {
+			log.Fatal(err)
+			return err
+		}
+		outages = append(outages, outage)
+	}
+	if err = routeCtrl.SetOutages(outages); err != nil {
+		log.Fatal(err)
+		return err
+	}
+	if err = routeCtrl.SetNoise(noise); err != nil {
+		log.Fatal(err)
+		return err
+	}
+	if err = routeCtrl.SetEnd(mainCfg.End); err != nil {
+		log.Fatal(err)
+		return err
+	}
+	simClock, err := newClock(mainCfg)
+	if err != nil {
+		log.Fatal(err)
+		return err
+	}
+	if err = routeCtrl.SetClock(simClock); err != nil {
+		log.Fatal(err)
+		return err
+	}
+	routeCtrl.SetStepping(mainCfg.Stepping)
 	routeCtrl.Startup()
 	defer routeCtrl.Shutdown()
 
+	var skyModel sky.Model = sky.NewSimulatedModel(skyCfg)
+	if mainCfg.Almanac != "" {
+		if skyModel, err = sky.LoadAlmanac(mainCfg.Almanac, skyCfg.ElevationMask, simClock.LeapSeconds); err != nil {
+			log.Fatal(err)
+			return err
+		}
+		log.Infof("Sky: satellites positions are computed from %s", mainCfg.Almanac)
+	}
+
+	// start the virtual serial device before gpsd server, so DEVICES could report its path
+	if mainCfg.Pty || mainCfg.PtyLink != "" {
+		ptyOutput, err := pty.NewOutput(ctx, log, routeCtrl, nmeaCfg, skyModel, writerCfg.TpvMode, mainCfg.PtyLink)
+		if err != nil {
+			log.Fatal(err)
+			return err
+		}
+		if err = ptyOutput.Startup(); err != nil {
+			log.Fatal(err)
+			return err
+		}
+		defer ptyOutput.Shutdown()
+		if !mainCfg.DevicePathChanged {
+			writerCfg.DevicePath = ptyOutput.DevicePath()
+		}
+	}
+
 	// start gpsd simulator server
-	gpsdServer, err := gpsd.NewServer(ctx, mainCfg.GpsdPort, log, routeCtrl, writerCfg)
+	gpsdServer, err := gpsd.NewServer(ctx, mainCfg.GpsdPort, log, routeCtrl, writerCfg, nmeaCfg, skyModel)
 	if err != nil {
 		log.Fatal(err)
 		return err
 	}
+	if mainCfg.Replay != "" {
+		recording, err := gpsd.LoadRecording(mainCfg.Replay, mainCfg.ReplayRebase)
+		if err != nil {
+			log.Fatal(err)
+			return err
+		}
+		gpsdServer.SetRecording(recording)
+	}
 	defer gpsdServer.Shutdown()
 	if err = gpsdServer.Startup(); err != nil {
 		log.Fatal(err)
 		return err
 	}
 
-	// start http server
-	httpServer, err := http.NewServer(ctx, mainCfg.WebUiPort, log, routeCtrl)
-	if err != nil {
-		log.Fatal(err)
-		return err
-	}
-	defer httpServer.Shutdown()
-	go func() {
-		if err = httpServer.Startup(); err != nil {
-			log.Info(err)
+	// start http server, the headless mode has no web UI, so its clients don't change the pace of the simulation
+	if !mainCfg.Headless {
+		httpServer, err := http.NewServer(ctx, mainCfg.WebUiPort, log, routeCtrl)
+		if err != nil {
+			log.Fatal(err)
+			return err
 		}
-	}()
+		defer httpServer.Shutdown()
+		go func() {
+			if err = httpServer.Startup(); err != nil {
+				log.Info(err)
+			}
+		}()
+	}
 
 	// try to load route from file if specified
 	if err = routeCtrl.LoadRouteFromFile(mainCfg.File); err != nil {
 		log.Errorf("error loading route from file %s: %v", mainCfg.File, err)
+		if mainCfg.Headless {
+			return err
+		}
+	}
+
+	if mainCfg.Stdin {
+		go readCommands(os.Stdin, log, routeCtrl)
 	}
 
-	<-signalCtx.Done()
+	select {
+	case <-signalCtx.Done():
+	case <-routeCtrl.Finished():
+		log.Infof("the route has ended")
+	}
 	log.Infof("starting graceful shutdown process")
 	return nil
 }
+
+// applyGpsdProfile overrides the writer config with the profile, except the values set explicitly with the flags
+func applyGpsdProfile(cmd *cobra.Command, name string, writerCfg *gpsd.WriterConfig) error {
+	if name == "" {
+		return nil
+	}
+	profile, err := gpsd.LookupProfile(name)
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("version-release") {
+		writerCfg.VersionRelease = profile.Release
+	}
+	if !flags.Changed("version-revision") {
+		writerCfg.VersionRev = profile.Release
+	}
+	if !flags.Changed("version-proto-major") {
+		writerCfg.VersionProtoMajor = profile.ProtoMajor
+	}
+	if !flags.Changed("version-proto-minor") {
+		writerCfg.VersionProtoMinor = profile.ProtoMinor
+	}
+	if !flags.Changed("tpv-fields") {
+		writerCfg.TpvFields = profile.TpvFields
+	}
+	if !flags.Changed("sky-fields") {
+		writerCfg.SkyFields = profile.SkyFields
+	}
+	if !flags.Changed("device-fields") {
+		writerCfg.DeviceFields = profile.DeviceFields
+	}
+	if !flags.Changed("device-activated") {
+		writerCfg.DeviceActivatedSeconds = profile.DeviceActivatedSeconds
+	}
+	return nil
+}
+
+// applyVehicle limits the TPV fields of the gpsd profile to the ones the vehicle reports, unless they are set explicitly
+func applyVehicle(cmd *cobra.Command, name string, writerCfg *gpsd.WriterConfig) error {
+	if name == "" {
+		return nil
+	}
+	vehicle, err := route.LookupVehicle(name)
+	if err != nil {
+		return err
+	}
+	if vehicle.TpvFields == nil || cmd.Flags().Changed("tpv-fields") {
+		return nil
+	}
+	fields := make([]string, 0, len(vehicle.TpvFields))
+	for _, field := range writerCfg.TpvFields {
+		if slices.Contains(vehicle.TpvFields, field) {
+			fields = append(fields, field)
+		}
+	}
+	writerCfg.TpvFields = fields
+	return nil
+}
+
+func newClock(mainCfg *mainConfig) (*clock.SimClock, error) {
+	clockCfg := clock.Config{
+		Offset: mainCfg.ClockOffset,
+		Scale:  mainCfg.TimeScale,
+	}
+	if mainCfg.ClockStart != "" {
+		start, err := time.Parse(time.RFC3339Nano, mainCfg.ClockStart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clock start %q: %w", mainCfg.ClockStart, err)
+		}
+		clockCfg.Start = start
+	}
+	start := clockCfg.Start
+	if start.IsZero() {
+		start = time.Now()
+		if mainCfg.Headless {
+			start = clock.DefaultVirtualStart
+		}
+	}
+	for _, value := range mainCfg.ClockJumps {
+		jump, err := clock.ParseJump(value, start.Add(clockCfg.Offset))
+		if err != nil {
+			return nil, err
+		}
+		clockCfg.Jumps = append(clockCfg.Jumps, jump)
+	}
+	for _, value := range mainCfg.LeapSeconds {
+		leapSecond, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid leap second %q: %w", value, err)
+		}
+		clockCfg.LeapSeconds = append(clockCfg.LeapSeconds, leapSecond)
+	}
+	if mainCfg.Headless {
+		return clock.NewVirtual(clockCfg), nil
+	}
+	return clock.New(clockCfg), nil
+}
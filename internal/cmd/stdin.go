@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+)
+
+// readCommands controls the route with the commands read line by line: "step [count]", "run" and "pause"
+func readCommands(input io.Reader, log logger.Logger, routeCtrl *route.Controller) {
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := runCommand(fields, routeCtrl); err != nil {
+			log.Errorf("stdin: %v", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("stdin: read error: %v", err)
+	}
+}
+
+func runCommand(fields []string, routeCtrl *route.Controller) error {
+	switch fields[0] {
+	case "step":
+		count := 1
+		if len(fields) > 1 {
+			var err error
+			if count, err = strconv.Atoi(fields[1]); err != nil {
+				return fmt.Errorf("invalid step count %q", fields[1])
+			}
+		}
+		return routeCtrl.Step(count)
+	case "run":
+		routeCtrl.Run()
+	case "pause":
+		routeCtrl.Pause()
+	default:
+		return fmt.Errorf("unknown command %q, expected step [count], run or pause", fields[0])
+	}
+	return nil
+}
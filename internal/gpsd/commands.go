@@ -0,0 +1,211 @@
+package gpsd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+)
+
+type command struct {
+	name   string
+	params string
+}
+
+// parseCommand splits a client request like `?WATCH={"enable":true};` into its name and JSON parameters
+func parseCommand(line string) (command, error) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(line, string(CommandSuffix))
+	if !strings.HasPrefix(line, CommandPrefix) {
+		return command{}, fmt.Errorf("Missing leading '?' in request '%s'", line)
+	}
+
+	name, params, _ := strings.Cut(line, "=")
+	return command{name: name, params: strings.TrimSpace(params)}, nil
+}
+
+func (s *Server) dispatch(writer *Writer, watcher *client, line string) error {
+	cmd, err := parseCommand(line)
+	if err != nil {
+		return writer.WriteError(err.Error())
+	}
+
+	switch cmd.name {
+	case WatchCommand:
+		return s.handleWatch(writer, watcher, cmd.params)
+	case VersionCommand:
+		return writer.WriteVersion()
+	case DevicesCommand:
+		return writer.WriteDevices(s.devices.get())
+	case DeviceCommand:
+		return s.handleDevice(writer, cmd.params)
+	case StepCommand:
+		return s.handleStep(writer, cmd.params)
+	case PollCommand:
+		if s.recording != nil {
+			return s.pollRecorded(writer, watcher)
+		}
+		point, view, hasPoint := watcher.getLastPoint()
+		if !hasPoint {
+			return writer.WritePoll(s.routeCtrl.Now(), nil, nil)
+		}
+		return writer.WritePoll(point.Time, &point, &view)
+	default:
+		return writer.WriteError(fmt.Sprintf("Unrecognized request '%s'", strings.TrimPrefix(cmd.name, CommandPrefix)))
+	}
+}
+
+func (s *Server) handleWatch(writer *Writer, watcher *client, params string) error {
+	request, err := parseWatchRequest(params)
+	if err != nil {
+		s.log.Warnf("GPSD: %v", err)
+		return writer.WriteError(err.Error())
+	}
+
+	watchData := watcher.applyWatch(request)
+	s.log.Debugf("GPSD: WATCH policy updated: %+v", watchData)
+
+	if watchData.Enable && params != "" {
+		if err = writer.WriteDevices(s.devices.get()); err != nil {
+			return fmt.Errorf("DevicesLine write error: %w", err)
+		}
+	}
+	if err = writer.WriteWatch(watchData); err != nil {
+		return fmt.Errorf("WatchLine write error: %w", err)
+	}
+	if watchData.Enable {
+		watcher.startReports()
+	}
+
+	return nil
+}
+
+// stepRequest is the ?STEP= object of the simulator extension, count is 1 when omitted
+type stepRequest struct {
+	Class string `json:"class"`
+	Count int    `json:"count"`
+}
+
+// handleStep acknowledges the request before the points are sent, so the client reads STEP ahead of the reports
+func (s *Server) handleStep(writer *Writer, params string) error {
+	request := stepRequest{Count: 1}
+	if params != "" {
+		if err := json.Unmarshal([]byte(params), &request); err != nil {
+			return writer.WriteError(fmt.Sprintf("Invalid STEP: %v", err))
+		}
+	}
+	if request.Count < 1 {
+		return writer.WriteError(fmt.Sprintf("Invalid STEP count %d", request.Count))
+	}
+	if s.routeCtrl.GetRouteSize() == 0 {
+		return writer.WriteError("No route to step")
+	}
+
+	request.Class = "STEP"
+	if err := writer.WriteStep(request); err != nil {
+		return fmt.Errorf("StepLine write error: %w", err)
+	}
+	if err := s.routeCtrl.Step(request.Count); err != nil {
+		return writer.WriteError(fmt.Sprintf("STEP failed: %v", err))
+	}
+	return nil
+}
+
+// deviceRequest mirrors the ?DEVICE= object, only the settings a serial GPS receiver accepts are changeable
+type deviceRequest struct {
+	Path     *string  `json:"path"`
+	Bps      *uint    `json:"bps"`
+	Parity   *string  `json:"parity"`
+	Stopbits *uint    `json:"stopbits"`
+	Native   *uint    `json:"native"`
+	Cycle    *float64 `json:"cycle"`
+}
+
+func (s *Server) handleDevice(writer *Writer, params string) error {
+	if params == "" {
+		return writer.WriteDevice(s.devices.get())
+	}
+
+	var request deviceRequest
+	if err := json.Unmarshal([]byte(params), &request); err != nil {
+		return writer.WriteError(fmt.Sprintf("Invalid DEVICE: %v", err))
+	}
+
+	current := s.devices.get()
+	if request.Path != nil && *request.Path != current.Path {
+		return writer.WriteError(fmt.Sprintf("Device %s not found", *request.Path))
+	}
+	if request.Parity != nil && *request.Parity != "N" && *request.Parity != "O" && *request.Parity != "E" {
+		return writer.WriteError(fmt.Sprintf("Invalid DEVICE parity '%s'", *request.Parity))
+	}
+	if request.Stopbits != nil && *request.Stopbits != 1 && *request.Stopbits != 2 {
+		return writer.WriteError(fmt.Sprintf("Invalid DEVICE stopbits %d", *request.Stopbits))
+	}
+	if request.Cycle != nil && *request.Cycle < route.MinStepDelay.Seconds() {
+		return writer.WriteError(fmt.Sprintf("Invalid DEVICE cycle %.2f", *request.Cycle))
+	}
+
+	updated := s.devices.apply(request)
+	if request.Cycle != nil {
+		s.routeCtrl.SetStepDelay(time.Duration(*request.Cycle * float64(time.Second)))
+	}
+	s.log.Infof("GPSD: device settings updated: bps=%d, parity=%s, stopbits=%d, native=%d, cycle=%.2f",
+		updated.Bps, updated.Parity, updated.Stopbits, updated.Native, updated.Cycle)
+
+	return writer.WriteDevice(updated)
+}
+
+// deviceState holds the simulated receiver settings, which are shared by all the clients as in gpsd
+type deviceState struct {
+	mu     sync.Mutex
+	device device
+}
+
+func newDeviceState(config WriterConfig, cycle time.Duration) *deviceState {
+	return &deviceState{
+		device: device{
+			Path:      config.DevicePath,
+			Driver:    config.DeviceDriver,
+			Activated: config.DeviceActivated,
+			Flags:     1,
+			Native:    0,
+			Bps:       config.DeviceBps,
+			Parity:    config.DeviceParity,
+			Stopbits:  config.DeviceStopBits,
+			Cycle:     cycle.Seconds(),
+			Mincycle:  route.MinStepDelay.Seconds(),
+		},
+	}
+}
+
+func (d *deviceState) get() device {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.device
+}
+
+func (d *deviceState) apply(request deviceRequest) device {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if request.Bps != nil {
+		d.device.Bps = *request.Bps
+	}
+	if request.Parity != nil {
+		d.device.Parity = *request.Parity
+	}
+	if request.Stopbits != nil {
+		d.device.Stopbits = *request.Stopbits
+	}
+	if request.Native != nil {
+		d.device.Native = *request.Native
+	}
+	if request.Cycle != nil {
+		d.device.Cycle = *request.Cycle
+	}
+
+	return d.device
+}
@@ -0,0 +1,135 @@
+package gpsd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line   string
+		name   string
+		params string
+		err    string
+	}{
+		{line: "?VERSION;", name: VersionCommand},
+		{line: "  ?POLL\r", name: PollCommand},
+		{line: `?WATCH={"enable":true,"json":true};`, name: WatchCommand, params: `{"enable":true,"json":true}`},
+		{line: `?DEVICE= {"bps":4800} `, name: DeviceCommand, params: `{"bps":4800}`},
+		{line: "?WATCH=", name: WatchCommand},
+		{line: "VERSION;", err: "Missing leading '?' in request 'VERSION'"},
+	}
+	for _, test := range tests {
+		cmd, err := parseCommand(test.line)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: got error %v, want %q", test.line, err, test.err)
+			}
+			continue
+		}
+		if err != nil || cmd.name != test.name || cmd.params != test.params {
+			t.Errorf("%q: got %+v and error %v, want %s with %q", test.line, cmd, err, test.name, test.params)
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	device := `{"class":"DEVICE","path":"/dev/ttyUSB1","driver":"NMEA0183","activated":"2025-03-21T12:20:29.002Z","flags":1,"native":0,"bps":9600,"parity":"N","stopbits":1,"cycle":1.00,"mincycle":0.05}`
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"?VERSION;", `{"class":"VERSION","release":"3.25","rev":"3.25","proto_major":3,"proto_minor":15}`},
+		{"?DEVICES;", `{"class":"DEVICES","devices":[` + device + `]}`},
+		{"?DEVICE;", device},
+		{"?POLL;", `{"class":"POLL","time":"2025-01-01T00:00:00Z","active":0,"tpv":[],"sky":[]}`},
+		{"?FOO;", `{"class":"ERROR","message":"Unrecognized request 'FOO'"}`},
+		{"VERSION", `{"class":"ERROR","message":"Missing leading '?' in request 'VERSION'"}`},
+	}
+	server := testServer()
+	// the virtual clock stands still, so POLL is stamped with its start before any point
+	if err := server.routeCtrl.SetClock(clock.NewVirtual(clock.Config{})); err != nil {
+		t.Fatal(err)
+	}
+	config := server.writerConfig
+	config.VersionRelease, config.VersionRev = DefaultVersionRelease, DefaultVersionRev
+	config.VersionProtoMajor, config.VersionProtoMinor = DefaultVersionProtoMajor, DefaultVersionProtoMinor
+	var output bytes.Buffer
+	writer := NewWriter(&output, config)
+	watcher := newClient(func() {})
+	for _, test := range tests {
+		output.Reset()
+		if err := server.dispatch(writer, watcher, test.line); err != nil {
+			t.Fatalf("%s: %v", test.line, err)
+		}
+		if got := strings.TrimSpace(output.String()); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.line, got, test.want)
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	server := testServer()
+	var output bytes.Buffer
+	writer := NewWriter(&output, WriterConfig{DevicePath: DefaultVersionDevicePath, TpvMode: DefaultTpvMode, TpvFields: []string{"lat", "lon"}, SkyFields: []string{}})
+	watcher := newClient(func() {})
+	at := time.Date(2025, time.June, 13, 17, 29, 0, 500000000, time.UTC)
+	point := route.Point{Lat: 47.5, Lon: 8.25, Time: at, Mode: 3}
+	watcher.setLastPoint(point, sky.NewView([]sky.Satellite{{PRN: 1, GnssId: sky.GnssIdGPS, SvId: 1, Elevation: 45, Used: true}}))
+
+	if err := server.dispatch(writer, watcher, "?POLL;"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"class":"POLL","time":"2025-06-13T17:29:00.5Z","active":1,` +
+		`"tpv":[{"class":"TPV","device":"/dev/ttyUSB1","mode":3,"time":"2025-06-13T17:29:00.5Z","lat":47.5,"lon":8.25}],` +
+		`"sky":[{"class":"SKY","device":"/dev/ttyUSB1","time":"2025-06-13T17:29:00.5Z","xdop":0.00,"ydop":0.00,"vdop":0.00,"tdop":0.00,"hdop":0.00,"gdop":0.00,"pdop":0.00,"satellites":[{"PRN":1,"el":45.0,"az":0.0,"ss":0.0,"used":true}]}]}`
+	if got := strings.TrimSpace(output.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHandleDevice(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+		cycle  time.Duration
+	}{
+		{`{"bps":4800,"parity":"E","stopbits":2,"native":1}`, `"native":1,"bps":4800,"parity":"E","stopbits":2,"cycle":1.00`, time.Second},
+		{`{"path":"/dev/ttyUSB1","cycle":0.2}`, `"native":0,"bps":9600,"parity":"N","stopbits":1,"cycle":0.20`, 200 * time.Millisecond},
+		{`{"path":"/dev/ttyACM0"}`, `Device /dev/ttyACM0 not found`, time.Second},
+		{`{"parity":"X"}`, `Invalid DEVICE parity 'X'`, time.Second},
+		{`{"stopbits":3}`, `Invalid DEVICE stopbits 3`, time.Second},
+		{`{"cycle":0.01}`, `Invalid DEVICE cycle 0.01`, time.Second},
+		{`{"bps":"fast"}`, `Invalid DEVICE: json: cannot unmarshal`, time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.params, func(t *testing.T) {
+			server := testServer()
+			var output bytes.Buffer
+			writer := NewWriter(&output, server.writerConfig)
+			if err := server.handleDevice(writer, test.params); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(output.String(), test.want) {
+				t.Errorf("got %s, want %s", output.String(), test.want)
+			}
+			if cycle := server.routeCtrl.StepDelay(); cycle != test.cycle {
+				t.Errorf("got the step delay %v, want %v", cycle, test.cycle)
+			}
+			// the settings are shared by the clients, so DEVICES reports the updated ones
+			output.Reset()
+			if err := writer.WriteDevices(server.devices.get()); err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(test.want, `"`) && !strings.Contains(output.String(), test.want) {
+				t.Errorf("got DEVICES %s, want %s", output.String(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package gpsd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Profile is the output layout of a particular gpsd release: the VERSION numbers and the TPV, SKY and DEVICE fields
+type Profile struct {
+	Release                string
+	ProtoMajor             uint
+	ProtoMinor             uint
+	TpvFields              []string
+	SkyFields              []string
+	DeviceFields           []string
+	DeviceActivatedSeconds bool
+}
+
+var (
+	// 3.17 and 3.18 report only the basic fix with its error estimates
+	tpvFields317 = []string{"ept", "lat", "lon", "alt", "epx", "epy", "epv", "track", "speed", "climb", "eps", "epc"}
+	// 3.19 adds ECEF and leap seconds
+	tpvFields319 = slices.Concat(tpvFields317, []string{"leapseconds", "ecefx", "ecefy", "ecefz", "ecefvx", "ecefvy", "ecefvz"})
+	// 3.20 splits the altitude into altHAE/altMSL and adds the magnetic track and the spherical errors
+	tpvFields320 = TpvFields
+
+	skyFields317 = []string{}
+	skyFields318 = []string{"gnssid", "svid"}
+	skyFields320 = SkyFields
+
+	// before 3.20 DEVICE has no mincycle and the activation time is in seconds since the epoch
+	deviceFields317 = []string{"activated", "flags", "native", "bps", "parity", "stopbits", "cycle"}
+	deviceFields320 = DeviceFields
+)
+
+var Profiles = map[string]Profile{
+	"3.17": {Release: "3.17", ProtoMajor: 3, ProtoMinor: 12, TpvFields: tpvFields317, SkyFields: skyFields317, DeviceFields: deviceFields317, DeviceActivatedSeconds: true},
+	"3.18": {Release: "3.18", ProtoMajor: 3, ProtoMinor: 13, TpvFields: tpvFields317, SkyFields: skyFields318, DeviceFields: deviceFields317, DeviceActivatedSeconds: true},
+	"3.19": {Release: "3.19", ProtoMajor: 3, ProtoMinor: 14, TpvFields: tpvFields319, SkyFields: skyFields318, DeviceFields: deviceFields317, DeviceActivatedSeconds: true},
+	"3.20": {Release: "3.20", ProtoMajor: 3, ProtoMinor: 14, TpvFields: tpvFields320, SkyFields: skyFields320, DeviceFields: deviceFields320},
+	"3.21": {Release: "3.21", ProtoMajor: 3, ProtoMinor: 14, TpvFields: tpvFields320, SkyFields: skyFields320, DeviceFields: deviceFields320},
+	"3.22": {Release: "3.22", ProtoMajor: 3, ProtoMinor: 14, TpvFields: tpvFields320, SkyFields: skyFields320, DeviceFields: deviceFields320},
+	"3.23": {Release: "3.23", ProtoMajor: 3, ProtoMinor: 15, TpvFields: tpvFields320, SkyFields: skyFields320, DeviceFields: deviceFields320},
+	"3.24": {Release: "3.24", ProtoMajor: 3, ProtoMinor: 15, TpvFields: tpvFields320, SkyFields: skyFields320, DeviceFields: deviceFields320},
+	"3.25": {Release: "3.25", ProtoMajor: 3, ProtoMinor: 15, TpvFields: tpvFields320, SkyFields: skyFields320, DeviceFields: deviceFields320},
+}
+
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func LookupProfile(name string) (Profile, error) {
+	profile, ok := Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown gpsd profile %q, expected one of %v", name, ProfileNames())
+	}
+	return profile, nil
+}
@@ -0,0 +1,72 @@
+package gpsd
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+func TestProfiles(t *testing.T) {
+	names := ProfileNames()
+	if !slices.IsSorted(names) || names[0] != "3.17" || names[len(names)-1] != DefaultVersionRelease {
+		t.Errorf("got profiles %v, want 3.17 to %s", names, DefaultVersionRelease)
+	}
+	for _, name := range names {
+		profile, err := LookupProfile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := WriterConfig{TpvFields: profile.TpvFields, SkyFields: profile.SkyFields, DeviceFields: profile.DeviceFields,
+			DeviceActivated: DefaultDeviceActivated, DeviceActivatedSeconds: profile.DeviceActivatedSeconds}
+		if err = config.Validate(); err != nil || profile.Release != name || profile.ProtoMajor != 3 {
+			t.Errorf("%s: got %+v and error %v", name, profile, err)
+		}
+	}
+
+	// the defaults are the latest profile
+	latest := Profiles[DefaultVersionRelease]
+	if latest.ProtoMajor != DefaultVersionProtoMajor || latest.ProtoMinor != DefaultVersionProtoMinor || !slices.Equal(latest.TpvFields, TpvFields) ||
+		!slices.Equal(latest.SkyFields, SkyFields) || !slices.Equal(latest.DeviceFields, DeviceFields) || latest.DeviceActivatedSeconds {
+		t.Errorf("got %+v, want the default output", latest)
+	}
+
+	if _, err := LookupProfile("2.95"); err == nil || !strings.Contains(err.Error(), `unknown gpsd profile "2.95"`) {
+		t.Errorf("got error %v, want the unknown profile", err)
+	}
+}
+
+// TestProfileOutput checks the output of the oldest profile differs from the latest one the way gpsd 3.17 did
+func TestProfileOutput(t *testing.T) {
+	profile := Profiles["3.17"]
+	var output bytes.Buffer
+	writer := NewWriter(&output, WriterConfig{DevicePath: DefaultVersionDevicePath, DeviceActivated: DefaultDeviceActivated, TpvMode: 3,
+		TpvFields: profile.TpvFields, SkyFields: profile.SkyFields, DeviceFields: profile.DeviceFields, DeviceActivatedSeconds: profile.DeviceActivatedSeconds})
+	point := route.Point{Lat: 47.38, Lon: 8.44, Elevation: 500, Time: time.Date(2025, time.June, 13, 17, 29, 0, 0, time.UTC)}
+	view := sky.NewView([]sky.Satellite{{PRN: 1, GnssId: sky.GnssIdGPS, SvId: 1, Elevation: 45, Used: true}})
+
+	if err := writer.WriteTPVReport(point, view); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteSkyReport(view, point.Time); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteDevice(device{Path: DefaultVersionDevicePath, Activated: DefaultDeviceActivated, Cycle: 1, Mincycle: 0.05}); err != nil {
+		t.Fatal(err)
+	}
+	got := output.String()
+	for _, field := range []string{`"alt":500.00`, `"activated":1742559629.`} {
+		if !strings.Contains(got, field) {
+			t.Errorf("no %s in\n%s", field, got)
+		}
+	}
+	for _, field := range []string{"altHAE", "altMSL", "leapseconds", "ecefx", "eph", "nSat", "gnssid", "mincycle", "2025-03-21"} {
+		if strings.Contains(got, `"`+field) {
+			t.Errorf("got %s in\n%s", field, got)
+		}
+	}
+}
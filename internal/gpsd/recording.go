@@ -0,0 +1,232 @@
+package gpsd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordedNmea is the class of the NMEA sentences in the recording, gpspipe -w -r mixes them with the reports
+const recordedNmea = "NMEA"
+
+// recordedAnswers are the responses to the commands of gpspipe, the server answers the commands of its clients itself
+var recordedAnswers = map[string]bool{"VERSION": true, "DEVICES": true, "WATCH": true}
+
+var (
+	recordedTimeField    = regexp.MustCompile(`"(time|activated)":"([^"]+)"`)
+	recordedSecondsField = regexp.MustCompile(`"(real_sec|clock_sec)":(\d+)`)
+)
+
+// Recording is a gpsd JSON log, the output of gpspipe -w, replayed to every watching client from its start in
+// place of the simulated reports. The reports are sent as recorded with the gaps between them, taken from the
+// gpspipe -u timestamps when the lines have them or from the report times. With rebase the report times are moved
+// by whole seconds, so the recording starts at the time the client starts watching.
+type Recording struct {
+	reports []recordedReport
+	start   time.Time
+	rebase  bool
+}
+
+type recordedReport struct {
+	// at is the time since the first report
+	at    time.Duration
+	class string
+	line  string
+}
+
+func LoadRecording(path string, rebase bool) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	recording, err := readRecording(file, rebase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the recording %s: %w", path, err)
+	}
+	return recording, nil
+}
+
+func readRecording(input io.Reader, rebase bool) (*Recording, error) {
+	recording := &Recording{rebase: rebase}
+	var first time.Time
+	var at time.Duration
+	scanner := bufio.NewScanner(input)
+	// the SKY reports with all the satellites are longer than the default token
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		received, line := splitRecordedLine(scanner.Text())
+		report := recordedReport{line: line}
+		switch {
+		case strings.HasPrefix(line, "{"):
+			var header struct {
+				Class    string `json:"class"`
+				Time     string `json:"time"`
+				RealSec  int64  `json:"real_sec"`
+				RealNsec int64  `json:"real_nsec"`
+			}
+			if err := json.Unmarshal([]byte(line), &header); err != nil || header.Class == "" || recordedAnswers[header.Class] {
+				continue
+			}
+			report.class = header.Class
+			reported, _ := time.Parse(time.RFC3339Nano, header.Time)
+			if header.RealSec > 0 {
+				reported = time.Unix(header.RealSec, header.RealNsec)
+			}
+			if recording.start.IsZero() && !reported.IsZero() {
+				recording.start = reported
+			}
+			if received.IsZero() {
+				received = reported
+			}
+		case strings.HasPrefix(line, "$"), strings.HasPrefix(line, "!"):
+			report.class = recordedNmea
+		default:
+			continue
+		}
+
+		// the reports without a time, or the ones of an earlier time, are sent together with the previous one
+		if !received.IsZero() {
+			if first.IsZero() {
+				first = received
+			}
+			at = max(at, received.Sub(first))
+		}
+		report.at = at
+		recording.reports = append(recording.reports, report)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(recording.reports) == 0 {
+		return nil, errors.New("no gpsd reports")
+	}
+	return recording, nil
+}
+
+// splitRecordedLine splits the gpspipe -u timestamp, the seconds since the epoch, from the report
+func splitRecordedLine(line string) (time.Time, string) {
+	line = strings.TrimSpace(line)
+	prefix, report, ok := strings.Cut(line, ": ")
+	if !ok || strings.HasPrefix(line, "{") {
+		return time.Time{}, line
+	}
+	seconds, err := strconv.ParseFloat(prefix, 64)
+	if err != nil {
+		return time.Time{}, line
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), strings.TrimSpace(report)
+}
+
+// wanted tells whether the client watch policy asks for the report
+func (r recordedReport) wanted(watchData watch) bool {
+	switch r.class {
+	case recordedNmea:
+		return watchData.Nmea || watchData.Raw > 0
+	case "PPS":
+		return watchData.Json && watchData.Pps
+	case "TOFF":
+		return watchData.Json && watchData.Timing
+	}
+	return watchData.Json
+}
+
+// offset is the whole seconds from the start of the recording to now, zero without rebase
+func (r *Recording) offset(now time.Time) time.Duration {
+	if !r.rebase || r.start.IsZero() {
+		return 0
+	}
+	return time.Duration(now.Unix()-r.start.Unix()) * time.Second
+}
+
+// rebaseReport moves the times of the JSON report by the offset, keeping their precision
+func rebaseReport(line string, offset time.Duration) string {
+	if offset == 0 || !strings.HasPrefix(line, "{") {
+		return line
+	}
+	line = recordedTimeField.ReplaceAllStringFunc(line, func(field string) string {
+		match := recordedTimeField.FindStringSubmatch(field)
+		value, err := time.Parse(time.RFC3339Nano, match[2])
+		if err != nil {
+			return field
+		}
+		layout := "2006-01-02T15:04:05"
+		if dot := strings.IndexByte(match[2], '.'); dot >= 0 {
+			layout += "." + strings.Repeat("0", len(strings.TrimRight(match[2][dot+1:], "Z")))
+		}
+		return fmt.Sprintf(`"%s":"%s"`, match[1], value.Add(offset).UTC().Format(layout+"Z"))
+	})
+	return recordedSecondsField.ReplaceAllStringFunc(line, func(field string) string {
+		match := recordedSecondsField.FindStringSubmatch(field)
+		seconds, err := strconv.ParseInt(match[2], 10, 64)
+		if err != nil {
+			return field
+		}
+		return fmt.Sprintf(`"%s":%d`, match[1], seconds+int64(offset/time.Second))
+	})
+}
+
+// recordedTime is the time of the recorded JSON report, zero when it has none
+func recordedTime(line string) time.Time {
+	var header struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal([]byte(line), &header); err != nil {
+		return time.Time{}
+	}
+	return header.Time
+}
+
+// replayReports sends the recording to the client from its start, the gaps between the reports follow the time
+// scale. It returns when the recording ends.
+func (s *Server) replayReports(ctx context.Context, writer *Writer, watcher *client) {
+	offset := s.recording.offset(time.Now())
+	var at time.Duration
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for _, report := range s.recording.reports {
+		if report.at > at {
+			timer.Reset(time.Duration(float64(report.at-at) / s.routeCtrl.TimeScale()))
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			at = report.at
+		}
+
+		line := rebaseReport(report.line, offset)
+		watcher.setLastRecorded(report, line)
+		watchData := watcher.getWatch()
+		if !watchData.Enable || !watchData.watchesDevice(s.writerConfig.DevicePath) || !report.wanted(watchData) {
+			continue
+		}
+		if err := writer.WriteRecorded(line); err != nil {
+			s.log.Errorf("GPSD: failed to replay the %s report: %v", report.class, err)
+			return
+		}
+	}
+	s.log.Info("GPSD: the recording has ended")
+}
+
+// pollRecorded answers POLL with the last replayed TPV and SKY reports, at the time of the latest of them
+func (s *Server) pollRecorded(writer *Writer, watcher *client) error {
+	tpvLine, skyLine := watcher.getLastRecorded()
+	at := recordedTime(tpvLine)
+	if skyTime := recordedTime(skyLine); skyTime.After(at) {
+		at = skyTime
+	}
+	if at.IsZero() {
+		at = s.routeCtrl.Now()
+	}
+	return writer.WriteRecordedPoll(at, tpvLine, skyLine)
+}
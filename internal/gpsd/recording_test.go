@@ -0,0 +1,228 @@
+package gpsd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+)
+
+const (
+	recordedTpv = `{"class":"TPV","device":"/dev/ttyUSB0","mode":3,"time":"2025-06-13T17:29:00.000Z","lat":47.1,"lon":9.5}`
+	recordedSky = `{"class":"SKY","device":"/dev/ttyUSB0","time":"2025-06-13T17:29:00.500Z","satellites":[]}`
+)
+
+// TestPollRecorded checks POLL answers with the last replayed reports, even when the client isn't watching them
+func TestPollRecorded(t *testing.T) {
+	recording, err := readRecording(strings.NewReader(recordedTpv+"\n"+recordedSky+"\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testServer()
+	server.recording = recording
+	var output bytes.Buffer
+	writer := NewWriter(&output, WriterConfig{})
+	watcher := newClient(func() {})
+
+	if err = server.dispatch(writer, watcher, "?POLL;"); err != nil {
+		t.Fatal(err)
+	}
+	var empty struct {
+		Active uint              `json:"active"`
+		Tpv    []json.RawMessage `json:"tpv"`
+	}
+	if err = json.Unmarshal(output.Bytes(), &empty); err != nil || empty.Active != 0 || empty.Tpv == nil || len(empty.Tpv) != 0 {
+		t.Errorf("got %s before the replay, want an inactive POLL", output.String())
+	}
+
+	server.replayReports(context.Background(), writer, watcher)
+	output.Reset()
+	if err = server.dispatch(writer, watcher, "?POLL;"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"class":"POLL","time":"2025-06-13T17:29:00.5Z","active":1,"tpv":[` + recordedTpv + `],"sky":[` + recordedSky + `]}` + "\n"
+	if output.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", output.String(), want)
+	}
+}
+
+// gpsLog is the gpspipe -w -r -u output, the times are the seconds since the epoch when the lines were received
+const gpsLog = `1749835740.25: {"class":"VERSION","release":"3.25","rev":"3.25","proto_major":3,"proto_minor":15}
+1749835740.25: {"class":"DEVICES","devices":[{"class":"DEVICE","path":"/dev/ttyUSB0"}]}
+1749835740.25: {"class":"WATCH","enable":true,"json":true,"nmea":true}
+1749835740.5: {"class":"TPV","device":"/dev/ttyUSB0","mode":3,"time":"2025-06-13T17:29:00.000Z"}
+1749835740.75: $GPGGA,172900.00,4706.0000,N,00930.0000,E,1,08,0.9,500.0,M,47.0,M,,*5C
+1749835741.5: {"class":"SKY","device":"/dev/ttyUSB0","time":"2025-06-13T17:29:01.000Z","satellites":[]}
+1749835741.25: {"class":"TPV","device":"/dev/ttyUSB0","mode":3,"time":"2025-06-13T17:29:01.000Z"}
+gpspipe: the device is gone
+{"class":"PPS","device":"/dev/ttyUSB0","real_sec":1749835743,"real_nsec":0,"clock_sec":1749835743,"clock_nsec":0}
+`
+
+func TestReadRecording(t *testing.T) {
+	recording, err := readRecording(strings.NewReader(gpsLog), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, time.June, 13, 17, 29, 0, 0, time.UTC)
+	if !recording.start.Equal(start) || !recording.rebase {
+		t.Errorf("got start %v and rebase %v, want %v with the rebase", recording.start, recording.rebase, start)
+	}
+	// the answers to gpspipe are skipped, the late TPV is sent with the SKY before it, the PPS time is its real time
+	want := []struct {
+		class string
+		at    time.Duration
+	}{
+		{"TPV", 0},
+		{recordedNmea, 250 * time.Millisecond},
+		{"SKY", time.Second},
+		{"TPV", time.Second},
+		{"PPS", 2500 * time.Millisecond},
+	}
+	if len(recording.reports) != len(want) {
+		t.Fatalf("got %d reports, want %d", len(recording.reports), len(want))
+	}
+	for i, report := range recording.reports {
+		// the seconds since the epoch keep the microseconds only
+		if report.class != want[i].class || report.at.Round(time.Microsecond) != want[i].at || !strings.HasPrefix(report.line, "{") && !strings.HasPrefix(report.line, "$") {
+			t.Errorf("report %d: got %s at %v: %s, want %s at %v", i, report.class, report.at, report.line, want[i].class, want[i].at)
+		}
+	}
+
+	// without the gpspipe times the gaps are taken from the report times
+	recording, err = readRecording(strings.NewReader(recordedTpv+"\n"+recordedSky+"\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at := recording.reports[1].at; at != 500*time.Millisecond {
+		t.Errorf("got the SKY at %v, want 500ms", at)
+	}
+
+	for _, input := range []string{"", "gpspipe: no devices\n", `{"class":"VERSION","release":"3.25"}` + "\n"} {
+		if _, err = readRecording(strings.NewReader(input), false); err == nil || err.Error() != "no gpsd reports" {
+			t.Errorf("%q: got error %v, want no gpsd reports", input, err)
+		}
+	}
+}
+
+func TestSplitRecordedLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		received time.Time
+		report   string
+	}{
+		{"1749835740.5: $GPGGA,172900.00*5C\r", time.Unix(1749835740, 500_000_000), "$GPGGA,172900.00*5C"},
+		{`1749835740: {"class":"TPV"}`, time.Unix(1749835740, 0), `{"class":"TPV"}`},
+		{`{"class":"TPV","tag": "GGA"}`, time.Time{}, `{"class":"TPV","tag": "GGA"}`},
+		{"gpspipe: the device is gone", time.Time{}, "gpspipe: the device is gone"},
+	}
+	for _, test := range tests {
+		received, report := splitRecordedLine(test.line)
+		if !received.Equal(test.received) || report != test.report {
+			t.Errorf("%q: got %v and %q, want %v and %q", test.line, received, report, test.received, test.report)
+		}
+	}
+}
+
+func TestRecordedWanted(t *testing.T) {
+	tests := []struct {
+		class string
+		watch watch
+		want  bool
+	}{
+		{"TPV", watch{Json: true}, true},
+		{"TPV", watch{Nmea: true}, false},
+		{recordedNmea, watch{Json: true}, false},
+		{recordedNmea, watch{Nmea: true}, true},
+		{recordedNmea, watch{Raw: 1}, true},
+		{"PPS", watch{Json: true}, false},
+		{"PPS", watch{Json: true, Pps: true}, true},
+		{"TOFF", watch{Pps: true}, false},
+		{"TOFF", watch{Json: true, Timing: true}, true},
+	}
+	for _, test := range tests {
+		if got := (recordedReport{class: test.class}).wanted(test.watch); got != test.want {
+			t.Errorf("%s with %+v: got %v, want %v", test.class, test.watch, got, test.want)
+		}
+	}
+}
+
+func TestRebaseReport(t *testing.T) {
+	start := time.Date(2025, time.June, 13, 17, 29, 0, 0, time.UTC)
+	recording := &Recording{start: start, rebase: true}
+	offset := recording.offset(start.Add(36*time.Hour + 1500*time.Millisecond))
+	if offset != 36*time.Hour+time.Second {
+		t.Fatalf("got offset %v, want the whole seconds", offset)
+	}
+	if (&Recording{start: start}).offset(start.Add(time.Hour)) != 0 {
+		t.Error("got the offset without the rebase")
+	}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`{"class":"TPV","time":"2025-06-13T17:29:00.000Z","lat":47.1}`, `{"class":"TPV","time":"2025-06-15T05:29:01.000Z","lat":47.1}`},
+		{`{"class":"SKY","time":"2025-06-13T17:29:00.5Z"}`, `{"class":"SKY","time":"2025-06-15T05:29:01.5Z"}`},
+		{`{"class":"DEVICE","activated":"2025-06-13T17:28:59Z"}`, `{"class":"DEVICE","activated":"2025-06-15T05:29:00Z"}`},
+		{`{"class":"PPS","real_sec":1749835740,"real_nsec":5,"clock_sec":1749835740}`, `{"class":"PPS","real_sec":1749965341,"real_nsec":5,"clock_sec":1749965341}`},
+		{`{"class":"TPV","time":"yesterday"}`, `{"class":"TPV","time":"yesterday"}`},
+		{`$GPZDA,172900.00,13,06,2025,00,00*68`, `$GPZDA,172900.00,13,06,2025,00,00*68`},
+	}
+	for _, test := range tests {
+		if got := rebaseReport(test.line, offset); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+// TestReplayReports checks the replay sends the client the recorded reports of its watch policy
+func TestReplayReports(t *testing.T) {
+	recording, err := readRecording(strings.NewReader(strings.ReplaceAll(gpsLog, "/dev/ttyUSB0", DefaultVersionDevicePath)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testServer()
+	server.recording = recording
+	if err = server.routeCtrl.SetTimeScale(route.MaxTimeScale); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		watch   string
+		classes []string
+	}{
+		{`{"enable":true,"json":true}`, []string{"TPV", "SKY", "TPV"}},
+		{`{"enable":true,"json":true,"nmea":true,"pps":true}`, []string{"TPV", recordedNmea, "SKY", "TPV", "PPS"}},
+		{`{"enable":true,"json":true,"device":"/dev/ttyS1"}`, nil},
+		{`{"enable":false}`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.watch, func(t *testing.T) {
+			request, err := parseWatchRequest(test.watch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			watcher := newClient(func() {})
+			watcher.applyWatch(request)
+			var output bytes.Buffer
+			server.replayReports(context.Background(), NewWriter(&output, server.writerConfig), watcher)
+
+			var classes []string
+			for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+				switch {
+				case line == "":
+				case strings.HasPrefix(line, "$"):
+					classes = append(classes, recordedNmea)
+				default:
+					classes = append(classes, strings.Split(line, `"`)[3])
+				}
+			}
+			if strings.Join(classes, ",") != strings.Join(test.classes, ",") {
+				t.Errorf("got %v, want %v", classes, test.classes)
+			}
+		})
+	}
+}
@@ -2,26 +2,35 @@ package gpsd
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"strings"
 
 	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+	"github.com/aokhrimenko/gpsd-simulator/internal/nmea"
 	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
 )
 
-func NewServer(ctx context.Context, port uint, log logger.Logger, routeCtrl *route.Controller, writerConfig WriterConfig) (*Server, error) {
+func NewServer(ctx context.Context, port uint, log logger.Logger, routeCtrl *route.Controller, writerConfig WriterConfig, nmeaConfig nmea.Config, skyModel sky.Model) (*Server, error) {
+	if err := writerConfig.Validate(); err != nil {
+		return nil, err
+	}
 	server := &Server{
 		log:          log,
 		addr:         fmt.Sprintf(":%d", port),
 		routeCtrl:    routeCtrl,
 		writerConfig: writerConfig,
+		devices:      newDeviceState(writerConfig, routeCtrl.StepDelay()),
+		skyModel:     skyModel,
 	}
 	server.ctx, server.cancel = context.WithCancel(ctx)
 	var err error
+	server.nmeaEncoder, err = nmea.NewEncoder(nmeaConfig)
 
 	return server, err
 }
@@ -34,6 +43,15 @@ type Server struct {
 	log          logger.Logger
 	routeCtrl    *route.Controller
 	writerConfig WriterConfig
+	devices      *deviceState
+	nmeaEncoder  *nmea.Encoder
+	skyModel     sky.Model
+	recording    *Recording
+}
+
+// SetRecording replays the recording to the clients instead of the simulated reports, must be called before Startup
+func (s *Server) SetRecording(recording *Recording) {
+	s.recording = recording
 }
 
 func (s *Server) Startup() (err error) {
@@ -67,11 +85,11 @@ func (s *Server) loop() {
 }
 
 func (s *Server) handleConnection(conn net.Conn) {
-	updates, unsubscribeFunc := s.routeCtrl.Subscribe()
 	s.log.Infof("GPSD: Serving %s", conn.RemoteAddr().String())
-	reader := bufio.NewReader(conn)
+	scanner := bufio.NewScanner(conn)
+	scanner.Split(scanCommands)
 	ctx, cancel := context.WithCancel(s.ctx)
-	tpvReportingStarted := false
+	unsubscribeFunc := func() {}
 
 	defer func() {
 		s.log.Infof("GPSD: Closing connection to %s", conn.RemoteAddr().String())
@@ -81,12 +99,35 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}()
 
 	writer := NewWriter(conn, s.writerConfig)
+	var watcher *client
+	watcher = newClient(func() {
+		if s.recording != nil {
+			go func() {
+				s.replayReports(ctx, writer, watcher)
+				_ = conn.Close()
+			}()
+			return
+		}
+		var updates chan route.Point
+		updates, unsubscribeFunc = s.routeCtrl.Subscribe()
+		go func() {
+			s.sendReports(ctx, writer, watcher, updates)
+			// the updates are closed when the simulation ends, closing the connection stops reading the commands
+			_ = conn.Close()
+		}()
+	})
 
 	if err := writer.WriteVersion(); err != nil {
 		s.log.Debug("GPSD: VersionLine write error:", err)
 		return
 	}
 
+	// on the virtual clock the points are produced only for the watching clients, so the first point they get
+	// is always the same, and the recording starts for every client when it starts watching
+	if !s.routeCtrl.Virtual() && s.recording == nil {
+		watcher.startReports()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -95,32 +136,39 @@ func (s *Server) handleConnection(conn net.Conn) {
 		default:
 		}
 
-		line, lineErr := reader.ReadString(CommandSuffix)
-		if lineErr != nil {
-			if lineErr != io.EOF {
-				s.log.Errorf("GPSD: read error: %s", lineErr)
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+				s.log.Errorf("GPSD: read error: %s", err)
 			}
 			break
 		}
+		line := strings.TrimSpace(scanner.Text())
 		s.log.Debugf("GPSD: Received: %s", line)
-		if strings.HasPrefix(line, WatchCommand) && !tpvReportingStarted {
-			tpvReportingStarted = true
-			go s.sendTpvReports(ctx, writer, updates)
+		if line == "" {
+			continue
+		}
+		if err := s.dispatch(writer, watcher, line); err != nil {
+			s.log.Errorf("GPSD: command %s handling failed: %v", line, err)
+			return
 		}
 	}
 
 }
 
-func (s *Server) sendTpvReports(ctx context.Context, writer *Writer, updates chan route.Point) {
-	if err := writer.WriteDevices(); err != nil {
-		s.log.Errorf("GPSD: DevicesLine write error failed: %v", err)
-		return
+// scanCommands splits the client input into commands, which gpsd accepts terminated either by ';' or by a newline
+func scanCommands(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, string(CommandSuffix)+"\n"); i >= 0 {
+		return i + 1, data[:i], nil
 	}
-	if err := writer.WriteWatch(); err != nil {
-		s.log.Errorf("GPSD: WatchLine write error failed: %v", err)
-		return
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
 	}
+	return 0, nil, nil
+}
 
+// sendReports drains the route updates for the whole connection lifetime, so a client which hasn't enabled
+// watching yet doesn't block the route controller, and writes only the reports its WATCH policy asks for
+func (s *Server) sendReports(ctx context.Context, writer *Writer, watcher *client, updates chan route.Point) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -130,10 +178,51 @@ func (s *Server) sendTpvReports(ctx context.Context, writer *Writer, updates cha
 			if !isOpen {
 				return
 			}
-			if err := writer.WriteTPVReport(point); err != nil {
-				s.log.Errorf("GPSD: sendTpvReports write error failed on point %s: %v", point, err)
-				return
+			view := point.Sky(s.skyModel, point.FixMode(s.writerConfig.TpvMode))
+			watcher.setLastPoint(point, view)
+			watchData := watcher.getWatch()
+			if !watchData.Enable || !watchData.watchesDevice(s.writerConfig.DevicePath) {
+				continue
+			}
+			if watchData.Pps {
+				if err := writer.WriteTimeOffset("PPS", point.Time, point.WallTime); err != nil {
+					s.log.Errorf("GPSD: sendReports PPS write error failed: %v", err)
+					return
+				}
+			}
+			if watchData.Timing {
+				if err := writer.WriteTimeOffset("TOFF", point.Time, point.WallTime); err != nil {
+					s.log.Errorf("GPSD: sendReports TOFF write error failed: %v", err)
+					return
+				}
+			}
+			if watchData.Nmea || watchData.Raw > 0 {
+				if err := writer.WriteNMEA(s.nmeaEncoder.Encode(s.nmeaFix(point, view))); err != nil {
+					s.log.Errorf("GPSD: sendReports NMEA write error failed on point %s: %v", point, err)
+					return
+				}
+			}
+			if watchData.Json {
+				if err := writer.WriteTPVReport(point, view); err != nil {
+					s.log.Errorf("GPSD: sendReports write error failed on point %s: %v", point, err)
+					return
+				}
+				if watcher.skyDue(s.writerConfig.SkyInterval) {
+					if err := writer.WriteSkyReport(view, point.Time); err != nil {
+						s.log.Errorf("GPSD: sendReports SKY write error failed on point %s: %v", point, err)
+						return
+					}
+				}
 			}
 		}
 	}
 }
+
+func (s *Server) nmeaFix(point route.Point, view sky.View) nmea.Fix {
+	return nmea.Fix{
+		Point: point,
+		Time:  point.Time.UTC(),
+		Mode:  point.FixMode(s.writerConfig.TpvMode),
+		Sky:   view,
+	}
+}
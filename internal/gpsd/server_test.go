@@ -0,0 +1,88 @@
+package gpsd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/nmea"
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+// reportClasses returns the class of every report written, NMEA for the sentences
+func reportClasses(t *testing.T, output string) []string {
+	t.Helper()
+	classes := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "$"):
+			classes = append(classes, "NMEA")
+		default:
+			var header struct {
+				Class string `json:"class"`
+			}
+			if err := json.Unmarshal([]byte(line), &header); err != nil {
+				t.Fatalf("%q: %v", line, err)
+			}
+			classes = append(classes, header.Class)
+		}
+	}
+	return classes
+}
+
+func TestSendReports(t *testing.T) {
+	encoder, err := nmea.NewEncoder(nmea.Config{Sentences: []string{"GGA", "RMC"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, time.June, 13, 17, 29, 0, 0, time.UTC)
+	point := route.Point{Lat: 47.38, Lon: 8.44, Speed: 10, Time: at, WallTime: at, Mode: 3,
+		Satellites: []sky.Satellite{{PRN: 1, GnssId: sky.GnssIdGPS, SvId: 1, Elevation: 45, Used: true}}}
+
+	tests := []struct {
+		name    string
+		params  string
+		classes []string
+	}{
+		{"not watching", `{"enable":false}`, []string{}},
+		{"JSON", `{"enable":true}`, []string{"TPV", "SKY"}},
+		{"NMEA", `{"enable":true,"nmea":true}`, []string{"NMEA", "NMEA"}},
+		{"raw", `{"enable":true,"raw":1}`, []string{"NMEA", "NMEA"}},
+		{"everything", `{"enable":true,"json":true,"nmea":true,"pps":true,"timing":true}`, []string{"PPS", "TOFF", "NMEA", "NMEA", "TPV", "SKY"}},
+		{"other device", `{"enable":true,"device":"/dev/ttyACM0"}`, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := testServer()
+			server.nmeaEncoder = encoder
+			server.writerConfig.SkyInterval = 1
+			var output bytes.Buffer
+			writer := NewWriter(&output, server.writerConfig)
+			watcher := newClient(func() {})
+			request, err := parseWatchRequest(test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			watcher.applyWatch(request)
+
+			updates := make(chan route.Point, 1)
+			updates <- point
+			close(updates)
+			server.sendReports(context.Background(), writer, watcher, updates)
+
+			if got := reportClasses(t, output.String()); !slices.Equal(got, test.classes) {
+				t.Errorf("got %v, want %v", got, test.classes)
+			}
+			// POLL answers with the last point whatever the watch policy is
+			if _, _, hasPoint := watcher.getLastPoint(); !hasPoint {
+				t.Error("got no last point")
+			}
+		})
+	}
+}
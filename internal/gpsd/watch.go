@@ -0,0 +1,163 @@
+package gpsd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+// watchRequest mirrors the ?WATCH= object. Pointers are used to tell the fields
+// sent by the client apart from the omitted ones, which keep their current values.
+type watchRequest struct {
+	Enable  *bool   `json:"enable"`
+	Json    *bool   `json:"json"`
+	Nmea    *bool   `json:"nmea"`
+	Raw     *int    `json:"raw"`
+	Scaled  *bool   `json:"scaled"`
+	Timing  *bool   `json:"timing"`
+	Split24 *bool   `json:"split24"`
+	Pps     *bool   `json:"pps"`
+	Device  *string `json:"device"`
+}
+
+func parseWatchRequest(params string) (watchRequest, error) {
+	var request watchRequest
+	if params == "" {
+		return request, nil
+	}
+	if err := json.Unmarshal([]byte(params), &request); err != nil {
+		return request, fmt.Errorf("Invalid WATCH: %w", err)
+	}
+	if request.Raw != nil && (*request.Raw < 0 || *request.Raw > 2) {
+		return request, fmt.Errorf("Invalid WATCH raw value: %d", *request.Raw)
+	}
+
+	return request, nil
+}
+
+func (w watch) apply(request watchRequest) watch {
+	if request.Enable != nil {
+		w.Enable = *request.Enable
+	}
+	if request.Json != nil {
+		w.Json = *request.Json
+	}
+	if request.Nmea != nil {
+		w.Nmea = *request.Nmea
+	}
+	if request.Raw != nil {
+		w.Raw = *request.Raw
+	}
+	if request.Scaled != nil {
+		w.Scaled = *request.Scaled
+	}
+	if request.Timing != nil {
+		w.Timing = *request.Timing
+	}
+	if request.Split24 != nil {
+		w.Split24 = *request.Split24
+	}
+	if request.Pps != nil {
+		w.Pps = *request.Pps
+	}
+	if request.Device != nil {
+		w.Device = *request.Device
+	}
+
+	// Same as gpsd: enabling the watcher without choosing any output means JSON
+	if w.Enable && !w.Json && !w.Nmea && w.Raw == 0 {
+		w.Json = true
+	}
+
+	return w
+}
+
+func (w watch) watchesDevice(path string) bool {
+	return w.Device == "" || w.Device == path
+}
+
+// client keeps the per-connection WATCH policy and the latest position shared by the command reader and the report sender
+type client struct {
+	mu           sync.Mutex
+	watch        watch
+	lastPoint    route.Point
+	lastView     sky.View
+	hasLastPoint bool
+	// lastTpv and lastSky are the latest replayed reports, rebased, which answer POLL in place of the last point
+	lastTpv string
+	lastSky string
+	reports uint
+	// subscribe starts sending the route updates to the client, it's called once
+	subscribe     func()
+	subscribeOnce sync.Once
+}
+
+func newClient(subscribe func()) *client {
+	return &client{
+		watch:     watch{Class: "WATCH"},
+		subscribe: subscribe,
+	}
+}
+
+func (c *client) startReports() {
+	c.subscribeOnce.Do(c.subscribe)
+}
+
+func (c *client) getWatch() watch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.watch
+}
+
+func (c *client) applyWatch(request watchRequest) watch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watch = c.watch.apply(request)
+	return c.watch
+}
+
+func (c *client) setLastPoint(point route.Point, view sky.View) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastPoint = point
+	c.lastView = view
+	c.hasLastPoint = true
+}
+
+func (c *client) getLastPoint() (route.Point, sky.View, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastPoint, c.lastView, c.hasLastPoint
+}
+
+func (c *client) setLastRecorded(report recordedReport, line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch report.class {
+	case "TPV":
+		c.lastTpv = line
+	case "SKY":
+		c.lastSky = line
+	}
+}
+
+func (c *client) getLastRecorded() (string, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastTpv, c.lastSky
+}
+
+// skyDue counts the TPV reports sent and tells whether a SKY report has to follow this one
+func (c *client) skyDue(interval uint) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if interval == 0 {
+		return false
+	}
+	due := c.reports%interval == 0
+	c.reports++
+	return due
+}
@@ -0,0 +1,143 @@
+package gpsd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+)
+
+// testServer returns the server with the default config, which isn't listening
+func testServer() *Server {
+	log := logger.NewStdoutLogger(logger.LevelFatal)
+	config := WriterConfig{
+		DevicePath:      DefaultVersionDevicePath,
+		DeviceDriver:    DefaultDeviceDriver,
+		DeviceActivated: DefaultDeviceActivated,
+		DeviceBps:       DefaultDeviceBps,
+		DeviceParity:    DefaultDeviceParity,
+		DeviceStopBits:  DefaultDeviceStopBits,
+		TpvMode:         DefaultTpvMode,
+	}
+	routeCtrl := route.NewController(context.Background(), time.Second, log)
+	return &Server{log: log, routeCtrl: routeCtrl, writerConfig: config, devices: newDeviceState(config, routeCtrl.StepDelay())}
+}
+
+func TestParseWatchRequest(t *testing.T) {
+	tests := []struct {
+		params string
+		want   watch
+		err    string
+	}{
+		{params: "", want: watch{}},
+		{params: `{"enable":true,"nmea":true,"raw":1,"device":"/dev/ttyUSB1"}`, want: watch{Enable: true, Nmea: true, Raw: 1, Device: "/dev/ttyUSB1"}},
+		{params: `{"enable":true,"nmea":true,"scaled":true,"timing":true,"split24":true,"pps":true}`, want: watch{Enable: true, Nmea: true, Scaled: true, Timing: true, Split24: true, Pps: true}},
+		{params: `{"enable":tru}`, err: "Invalid WATCH"},
+		{params: `{"raw":3}`, err: "Invalid WATCH raw value: 3"},
+		{params: `{"raw":-1}`, err: "Invalid WATCH raw value: -1"},
+	}
+	for _, test := range tests {
+		request, err := parseWatchRequest(test.params)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.params, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.params, err)
+		}
+		// applied to the disabled policy the request leaves the omitted fields unset
+		if got := (watch{}).apply(request); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.params, got, test.want)
+		}
+	}
+}
+
+func TestWatchApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  watch
+		params   string
+		want     watch
+		watching string
+	}{
+		{"enable means JSON", watch{}, `{"enable":true}`, watch{Enable: true, Json: true}, "/dev/ttyUSB1"},
+		{"NMEA only", watch{}, `{"enable":true,"nmea":true}`, watch{Enable: true, Nmea: true}, "/dev/ttyUSB1"},
+		{"raw only", watch{}, `{"enable":true,"raw":2}`, watch{Enable: true, Raw: 2}, "/dev/ttyUSB1"},
+		{"omitted fields kept", watch{Enable: true, Nmea: true, Pps: true}, `{"json":true}`, watch{Enable: true, Json: true, Nmea: true, Pps: true}, "/dev/ttyUSB1"},
+		{"JSON switched off falls back to JSON", watch{Enable: true, Json: true}, `{"json":false}`, watch{Enable: true, Json: true}, "/dev/ttyUSB1"},
+		{"disable keeps the outputs", watch{Enable: true, Json: true, Nmea: true}, `{"enable":false}`, watch{Json: true, Nmea: true}, "/dev/ttyUSB1"},
+		{"other device", watch{}, `{"enable":true,"device":"/dev/ttyACM0"}`, watch{Enable: true, Json: true, Device: "/dev/ttyACM0"}, "/dev/ttyACM0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := parseWatchRequest(test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := test.current.apply(request)
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if !got.watchesDevice(test.watching) || (test.watching != "/dev/ttyUSB1" && got.watchesDevice("/dev/ttyUSB1")) {
+				t.Errorf("got the watched device %q, want %q", got.Device, test.watching)
+			}
+		})
+	}
+}
+
+func TestHandleWatch(t *testing.T) {
+	server := testServer()
+	var output bytes.Buffer
+	writer := NewWriter(&output, WriterConfig{DevicePath: DefaultVersionDevicePath})
+	subscribed := 0
+	watcher := newClient(func() { subscribed++ })
+
+	tests := []struct {
+		line       string
+		want       string
+		subscribed int
+	}{
+		{"?WATCH;", `{"class":"WATCH","enable":false,"json":false,"nmea":false,"raw":0,"scaled":false,"timing":false,"split24":false,"pps":false}`, 0},
+		{`?WATCH={"enable":true,"json":true};`, `{"class":"DEVICES","devices":[{"class":"DEVICE","path":"/dev/ttyUSB1","driver":"NMEA0183","activated":"2025-03-21T12:20:29.002Z","flags":1,"native":0,"bps":9600,"parity":"N","stopbits":1,"cycle":1.00,"mincycle":0.05}]}
+{"class":"WATCH","enable":true,"json":true,"nmea":false,"raw":0,"scaled":false,"timing":false,"split24":false,"pps":false}`, 1},
+		// the current policy is reported without the devices, the reports are started once
+		{"?WATCH", `{"class":"WATCH","enable":true,"json":true,"nmea":false,"raw":0,"scaled":false,"timing":false,"split24":false,"pps":false}`, 1},
+		{`?WATCH={"raw":5}`, `{"class":"ERROR","message":"Invalid WATCH raw value: 5"}`, 1},
+	}
+	for _, test := range tests {
+		output.Reset()
+		if err := server.dispatch(writer, watcher, test.line); err != nil {
+			t.Fatalf("%s: %v", test.line, err)
+		}
+		if got := strings.TrimSpace(output.String()); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.line, got, test.want)
+		}
+		if subscribed != test.subscribed {
+			t.Errorf("%s: got %d subscriptions, want %d", test.line, subscribed, test.subscribed)
+		}
+	}
+}
+
+func TestSkyDue(t *testing.T) {
+	for _, test := range []struct {
+		interval uint
+		want     []bool
+	}{
+		{0, []bool{false, false, false, false}},
+		{1, []bool{true, true, true, true}},
+		{3, []bool{true, false, false, true}},
+	} {
+		watcher := newClient(func() {})
+		for i, want := range test.want {
+			if got := watcher.skyDue(test.interval); got != want {
+				t.Errorf("interval %d, report %d: got %v, want %v", test.interval, i, got, want)
+			}
+		}
+	}
+}
@@ -4,16 +4,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+	"github.com/aokhrimenko/gpsd-simulator/internal/wgs84"
 )
 
 const (
 	DefaultVersionRelease    = "3.25"
 	DefaultVersionRev        = "3.25"
 	DefaultVersionProtoMajor = 3
-	DefaultVersionProtoMinor = 25
+	DefaultVersionProtoMinor = 15
 	DefaultVersionDevicePath = "/dev/ttyUSB1"
 	DefaultDeviceDriver      = "NMEA0183"
 	DefaultDeviceActivated   = "2025-03-21T12:20:29.002Z"
@@ -21,9 +27,24 @@ const (
 	DefaultDeviceParity      = "N"
 	DefaultDeviceStopBits    = 1
 	DefaultTpvMode           = 3
+	DefaultSkyInterval       = 1
+	DefaultTpvStatus         = 1
+	// DefaultUere is the user equivalent range error in meters, multiplied by DOP it gives the TPV error estimates
+	DefaultUere = 5.0
 
-	WatchCommand  = `?WATCH=`
-	CommandSuffix = ';'
+	// velocityUere is the range rate error in m/s, Doppler measurements are much more precise than the ranges
+	velocityUere = 0.25
+	// timeError is the time uncertainty in seconds reported by a receiver without PPS
+	timeError = 0.005
+
+	CommandPrefix  = `?`
+	WatchCommand   = `?WATCH`
+	VersionCommand = `?VERSION`
+	DevicesCommand = `?DEVICES`
+	DeviceCommand  = `?DEVICE`
+	PollCommand    = `?POLL`
+	StepCommand    = `?STEP` // the simulator extension, sends the next points of the route in the stepping mode
+	CommandSuffix  = ';'
 )
 
 type WriterConfig struct {
@@ -38,6 +59,49 @@ type WriterConfig struct {
 	DeviceParity      string
 	DeviceStopBits    uint
 	TpvMode           uint
+	SkyInterval       uint
+	TpvStatus         uint
+	TpvFields         []string
+	SkyFields         []string
+	DeviceFields      []string
+	// DeviceActivatedSeconds reports DEVICE/activated as the seconds since the epoch, as gpsd before 3.20 did
+	DeviceActivatedSeconds bool
+	Uere                   float64
+}
+
+// TpvFields are all the optional TPV fields, class, device, mode and time are always reported
+var TpvFields = []string{
+	"status", "leapseconds", "ept", "lat", "lon", "altHAE", "altMSL", "alt", "epx", "epy", "epv", "track", "magtrack",
+	"magvar", "speed", "climb", "eps", "epc", "ecefx", "ecefy", "ecefz", "ecefvx", "ecefvy", "ecefvz", "ecefpAcc",
+	"ecefvAcc", "geoidSep", "eph", "sep",
+}
+
+// SkyFields are the optional SKY fields, the DOPs and the satellites PRN, el, az, ss and used are always reported
+var SkyFields = []string{"nSat", "uSat", "gnssid", "svid"}
+
+// DeviceFields are the optional DEVICE fields, class, path and driver are always reported
+var DeviceFields = []string{"activated", "flags", "native", "bps", "parity", "stopbits", "cycle", "mincycle"}
+
+func (c WriterConfig) Validate() error {
+	for _, field := range c.TpvFields {
+		if !slices.Contains(TpvFields, field) {
+			return fmt.Errorf("unsupported TPV field %q", field)
+		}
+	}
+	for _, field := range c.SkyFields {
+		if !slices.Contains(SkyFields, field) {
+			return fmt.Errorf("unsupported SKY field %q", field)
+		}
+	}
+	for _, field := range c.DeviceFields {
+		if !slices.Contains(DeviceFields, field) {
+			return fmt.Errorf("unsupported DEVICE field %q", field)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, c.DeviceActivated); c.DeviceActivatedSeconds && err != nil {
+		return fmt.Errorf("invalid DEVICE activated time %q: %w", c.DeviceActivated, err)
+	}
+	return nil
 }
 
 // {"class":"VERSION","release":"3.25","rev":"3.25","proto_major":3,"proto_minor":25}
@@ -49,18 +113,74 @@ type version struct {
 	ProtoMinor uint   `json:"proto_minor"`
 }
 
-// {"class":"TPV","device":"/dev/ttyUSB1","mode":3,"time":"2025-06-13T17:29:00.337902Z","lat":47.38184271474015,"lon":8.44824654879321,"alt":575,"altHAE":575,"track":91.13973909509252,"speed":15.277777777813657}
+// {"class":"TPV","device":"/dev/ttyUSB1","status":1,"mode":3,"time":"2025-06-13T17:29:00.337902Z","leapseconds":18,"ept":0.005,"lat":47.1739,"lon":9.54162,"altHAE":781.300,"altMSL":734.000,"alt":734.000,"epx":3.269,"epy":3.216,"epv":9.669,"track":311.843,"magtrack":308.943,"magvar":2.9,"speed":46.673,"climb":-1.250,"eps":0.229,"epc":0.483,"ecefx":4283961.53,"ecefy":720088.73,"ecefz":4655501.34,"ecefvx":-17.59,"ecefvy":-38.22,"ecefvz":20.25,"ecefpAcc":10.70,"ecefvAcc":0.54,"geoidSep":47.300,"eph":4.586,"sep":10.702}
 type tpv struct {
-	Class  string        `json:"class"`
-	Device string        `json:"device"`
-	Mode   uint          `json:"mode"`
-	Time   time.Time     `json:"time"`
-	Lat    float64       `json:"lat"`
-	Lon    float64       `json:"lon"`
-	Alt    float64Fixed3 `json:"alt"`
-	AltHAE float64Fixed3 `json:"altHAE"`
-	Track  float64Fixed3 `json:"track"`
-	Speed  float64Fixed3 `json:"speed"`
+	Class       string         `json:"class"`
+	Device      string         `json:"device"`
+	Status      *uint          `json:"status,omitempty"`
+	Mode        uint           `json:"mode"`
+	Time        time.Time      `json:"time"`
+	LeapSeconds *int           `json:"leapseconds,omitempty"`
+	Ept         *float64Fixed3 `json:"ept,omitempty"`
+	Lat         *float64       `json:"lat,omitempty"`
+	Lon         *float64       `json:"lon,omitempty"`
+	AltHAE      *float64Fixed3 `json:"altHAE,omitempty"`
+	AltMSL      *float64Fixed3 `json:"altMSL,omitempty"`
+	Alt         *float64Fixed3 `json:"alt,omitempty"`
+	Epx         *float64Fixed3 `json:"epx,omitempty"`
+	Epy         *float64Fixed3 `json:"epy,omitempty"`
+	Epv         *float64Fixed3 `json:"epv,omitempty"`
+	Track       *float64Fixed3 `json:"track,omitempty"`
+	MagTrack    *float64Fixed3 `json:"magtrack,omitempty"`
+	MagVar      *float64Fixed1 `json:"magvar,omitempty"`
+	Speed       *float64Fixed3 `json:"speed,omitempty"`
+	Climb       *float64Fixed3 `json:"climb,omitempty"`
+	Eps         *float64Fixed3 `json:"eps,omitempty"`
+	Epc         *float64Fixed3 `json:"epc,omitempty"`
+	EcefX       *float64Fixed2 `json:"ecefx,omitempty"`
+	EcefY       *float64Fixed2 `json:"ecefy,omitempty"`
+	EcefZ       *float64Fixed2 `json:"ecefz,omitempty"`
+	EcefVx      *float64Fixed2 `json:"ecefvx,omitempty"`
+	EcefVy      *float64Fixed2 `json:"ecefvy,omitempty"`
+	EcefVz      *float64Fixed2 `json:"ecefvz,omitempty"`
+	EcefPAcc    *float64Fixed2 `json:"ecefpAcc,omitempty"`
+	EcefVAcc    *float64Fixed2 `json:"ecefvAcc,omitempty"`
+	GeoidSep    *float64Fixed3 `json:"geoidSep,omitempty"`
+	Eph         *float64Fixed3 `json:"eph,omitempty"`
+	Sep         *float64Fixed3 `json:"sep,omitempty"`
+}
+
+// {"class":"SKY","device":"/dev/ttyUSB1","time":"2025-06-13T17:29:00.337Z","xdop":0.56,"ydop":0.71,"vdop":1.21,"tdop":0.74,"hdop":0.90,"gdop":1.69,"pdop":1.51,"nSat":9,"uSat":8,"satellites":[{"PRN":5,"el":61.0,"az":105.0,"ss":44.0,"used":true,"gnssid":0,"svid":5}]}
+type skyReport struct {
+	Class      string         `json:"class"`
+	Device     string         `json:"device"`
+	Time       time.Time      `json:"time"`
+	Xdop       float64Fixed2  `json:"xdop"`
+	Ydop       float64Fixed2  `json:"ydop"`
+	Vdop       float64Fixed2  `json:"vdop"`
+	Tdop       float64Fixed2  `json:"tdop"`
+	Hdop       float64Fixed2  `json:"hdop"`
+	Gdop       float64Fixed2  `json:"gdop"`
+	Pdop       float64Fixed2  `json:"pdop"`
+	NSat       *int           `json:"nSat,omitempty"`
+	USat       *int           `json:"uSat,omitempty"`
+	Satellites []skySatellite `json:"satellites"`
+}
+
+type skySatellite struct {
+	PRN    int           `json:"PRN"`
+	El     float64Fixed1 `json:"el"`
+	Az     float64Fixed1 `json:"az"`
+	Ss     float64Fixed1 `json:"ss"`
+	Used   bool          `json:"used"`
+	GnssId *int          `json:"gnssid,omitempty"`
+	SvId   *int          `json:"svid,omitempty"`
+}
+
+type float64Fixed1 float64
+
+func (f float64Fixed1) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%.1f", f)), nil
 }
 
 type float64Fixed2 float64
@@ -75,22 +195,39 @@ func (f float64Fixed3) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("%.2f", f)), nil
 }
 
-// {"class":"DEVICES","devices":[{"class":"DEVICE","path":"/dev/ttyUSB1","driver":"NMEA0183","activated":"2025-03-21T12:20:29.002Z","flags":1,"native":0,"bps":9600,"parity":"N","stopbits":1,"cycle":1.00}]}
+// device is the state of the simulated device, deviceReport the DEVICE report with the enabled fields of it
 type device struct {
-	Class     string        `json:"class"`
-	Path      string        `json:"path"`
-	Driver    string        `json:"driver"`
-	Activated string        `json:"activated"`
-	Flags     uint          `json:"flags"`
-	Native    uint          `json:"native"`
-	Bps       uint          `json:"bps"`
-	Parity    string        `json:"parity"`
-	Stopbits  uint          `json:"stopbits"`
-	Cycle     float64Fixed2 `json:"cycle"`
+	Path      string
+	Driver    string
+	Activated string
+	Flags     uint
+	Native    uint
+	Bps       uint
+	Parity    string
+	Stopbits  uint
+	Cycle     float64
+	Mincycle  float64
+}
+
+// {"class":"DEVICE","path":"/dev/ttyUSB1","driver":"NMEA0183","activated":"2025-03-21T12:20:29.002Z","flags":1,"native":0,"bps":9600,"parity":"N","stopbits":1,"cycle":1.00,"mincycle":0.05}
+type deviceReport struct {
+	Class     string         `json:"class"`
+	Path      string         `json:"path"`
+	Driver    string         `json:"driver"`
+	Activated any            `json:"activated,omitempty"`
+	Flags     *uint          `json:"flags,omitempty"`
+	Native    *uint          `json:"native,omitempty"`
+	Bps       *uint          `json:"bps,omitempty"`
+	Parity    *string        `json:"parity,omitempty"`
+	Stopbits  *uint          `json:"stopbits,omitempty"`
+	Cycle     *float64Fixed2 `json:"cycle,omitempty"`
+	Mincycle  *float64Fixed2 `json:"mincycle,omitempty"`
 }
+
+// {"class":"DEVICES","devices":[{"class":"DEVICE",...}]}
 type devices struct {
-	Class   string   `json:"class"`
-	Devices []device `json:"devices"`
+	Class   string         `json:"class"`
+	Devices []deviceReport `json:"devices"`
 }
 
 // {"class":"WATCH","enable":true,"json":true,"nmea":false,"raw":0,"scaled":false,"timing":false,"split24":false,"pps":false}
@@ -104,6 +241,42 @@ type watch struct {
 	Timing  bool   `json:"timing"`
 	Split24 bool   `json:"split24"`
 	Pps     bool   `json:"pps"`
+	Device  string `json:"device,omitempty"`
+}
+
+// {"class":"POLL","time":"2025-06-13T17:29:00.337Z","active":1,"tpv":[{"class":"TPV",...}],"sky":[]}
+type poll struct {
+	Class  string      `json:"class"`
+	Time   time.Time   `json:"time"`
+	Active uint        `json:"active"`
+	Tpv    []tpv       `json:"tpv"`
+	Sky    []skyReport `json:"sky"`
+}
+
+// recordedPoll is the POLL response in the replay mode, the reports are the recorded ones
+type recordedPoll struct {
+	Class  string            `json:"class"`
+	Time   time.Time         `json:"time"`
+	Active uint              `json:"active"`
+	Tpv    []json.RawMessage `json:"tpv"`
+	Sky    []json.RawMessage `json:"sky"`
+}
+
+// {"class":"ERROR","message":"Unrecognized request 'FOO'"}
+type errorReport struct {
+	Class   string `json:"class"`
+	Message string `json:"message"`
+}
+
+// {"class":"TOFF","device":"/dev/ttyUSB1","real_sec":1749835740,"real_nsec":0,"clock_sec":1749835740,"clock_nsec":337902000,"precision":-1}
+type timeOffset struct {
+	Class     string `json:"class"`
+	Device    string `json:"device"`
+	RealSec   int64  `json:"real_sec"`
+	RealNsec  int64  `json:"real_nsec"`
+	ClockSec  int64  `json:"clock_sec"`
+	ClockNsec int64  `json:"clock_nsec"`
+	Precision int    `json:"precision"`
 }
 
 func NewWriter(upstream io.Writer, config WriterConfig) *Writer {
@@ -111,62 +284,95 @@ func NewWriter(upstream io.Writer, config WriterConfig) *Writer {
 	encoder.SetEscapeHTML(false)
 
 	return &Writer{
-		encoder: encoder,
-		config:  config,
+		upstream: upstream,
+		encoder:  encoder,
+		config:   config,
 		tpv: tpv{
 			Class:  "TPV",
 			Device: config.DevicePath,
 			Mode:   config.TpvMode,
 		},
+		tpvFields:    enabledFields(config.TpvFields, TpvFields),
+		skyFields:    enabledFields(config.SkyFields, SkyFields),
+		deviceFields: enabledFields(config.DeviceFields, DeviceFields),
 	}
 }
 
+// enabledFields returns the set of the configured fields, all of them when nothing is configured
+func enabledFields(fields, all []string) map[string]bool {
+	if fields == nil {
+		fields = all
+	}
+	enabled := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		enabled[field] = true
+	}
+	return enabled
+}
+
 type Writer struct {
-	encoder *json.Encoder
-	config  WriterConfig
-	tpv     tpv
+	mu           sync.Mutex
+	upstream     io.Writer
+	encoder      *json.Encoder
+	config       WriterConfig
+	tpv          tpv
+	tpvFields    map[string]bool
+	skyFields    map[string]bool
+	deviceFields map[string]bool
 }
 
-func (w *Writer) WriteDevices() error {
-	// {"class":"DEVICES","devices":[{"class":"DEVICE","path":"/dev/ttyUSB1","driver":"NMEA0183","activated":"2025-03-21T12:20:29.002Z","flags":1,"native":0,"bps":9600,"parity":"N","stopbits":1,"cycle":1.00}]}
+func (w *Writer) WriteDevices(deviceData device) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	devicesData := devices{
-		Class: "DEVICES",
-		Devices: []device{
-			{
-				Class:     "DEVICE",
-				Path:      w.config.DevicePath,
-				Driver:    w.config.DeviceDriver,
-				Activated: w.config.DeviceActivated,
-				Flags:     1,
-				Native:    0,
-				Bps:       w.config.DeviceBps,
-				Parity:    w.config.DeviceParity,
-				Stopbits:  w.config.DeviceStopBits,
-				Cycle:     float64Fixed2(1.0),
-			},
-		},
+		Class:   "DEVICES",
+		Devices: []deviceReport{w.deviceReport(deviceData)},
 	}
 	return w.encoder.Encode(devicesData)
 }
 
-func (w *Writer) WriteWatch() error {
-	// {"class":"WATCH","enable":true,"json":true,"nmea":false,"raw":0,"scaled":false,"timing":false,"split24":false,"pps":false}
-	watchData := watch{
-		Class:   "WATCH",
-		Enable:  true,
-		Json:    true,
-		Nmea:    false,
-		Raw:     0,
-		Scaled:  false,
-		Timing:  false,
-		Split24: false,
-		Pps:     false,
+func (w *Writer) WriteDevice(deviceData device) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.encoder.Encode(w.deviceReport(deviceData))
+}
+
+// deviceReport fills the enabled DEVICE fields
+func (w *Writer) deviceReport(deviceData device) deviceReport {
+	report := deviceReport{
+		Class:    "DEVICE",
+		Path:     deviceData.Path,
+		Driver:   deviceData.Driver,
+		Flags:    optional(w.deviceFields["flags"], deviceData.Flags),
+		Native:   optional(w.deviceFields["native"], deviceData.Native),
+		Bps:      optional(w.deviceFields["bps"], deviceData.Bps),
+		Parity:   optional(w.deviceFields["parity"], deviceData.Parity),
+		Stopbits: optional(w.deviceFields["stopbits"], deviceData.Stopbits),
+		Cycle:    optional(w.deviceFields["cycle"], float64Fixed2(deviceData.Cycle)),
+		Mincycle: optional(w.deviceFields["mincycle"], float64Fixed2(deviceData.Mincycle)),
+	}
+	if w.deviceFields["activated"] {
+		report.Activated = deviceData.Activated
+		if activated, err := time.Parse(time.RFC3339Nano, deviceData.Activated); err == nil && w.config.DeviceActivatedSeconds {
+			report.Activated = float64Fixed3(float64(activated.UnixNano()) / float64(time.Second))
+		}
 	}
+	return report
+}
+
+func (w *Writer) WriteWatch(watchData watch) error {
+	// {"class":"WATCH","enable":true,"json":true,"nmea":false,"raw":0,"scaled":false,"timing":false,"split24":false,"pps":false}
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
 	return w.encoder.Encode(watchData)
 }
 
 func (w *Writer) WriteVersion() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	versionData := version{
 		Class:      "VERSION",
 		Release:    w.config.VersionRelease,
@@ -178,14 +384,239 @@ func (w *Writer) WriteVersion() error {
 	return w.encoder.Encode(versionData)
 }
 
-func (w *Writer) WriteTPVReport(point route.Point) error {
-	w.tpv.Time = time.Now().UTC()
-	w.tpv.Lat = point.Lat
-	w.tpv.Lon = point.Lon
-	w.tpv.Alt = float64Fixed3(point.Elevation)
-	w.tpv.AltHAE = float64Fixed3(point.Elevation)
-	w.tpv.Track = float64Fixed3(point.Track)
-	w.tpv.Speed = float64Fixed3(point.Speed)
+func (w *Writer) WriteTPVReport(point route.Point, view sky.View) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.encoder.Encode(w.tpvReport(point, view))
+}
+
+func (w *Writer) WriteSkyReport(view sky.View, at time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.encoder.Encode(w.skyReport(view, at))
+}
+
+// WritePoll writes the POLL response at the simulated time, point is nil when nothing has been broadcast yet
+func (w *Writer) WritePoll(at time.Time, point *route.Point, view *sky.View) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pollData := poll{
+		Class:  "POLL",
+		Time:   at.UTC(),
+		Active: 0,
+		Tpv:    make([]tpv, 0, 1),
+		Sky:    make([]skyReport, 0, 1),
+	}
+	if point != nil {
+		pollData.Active = 1
+		var tpvView sky.View
+		if view != nil {
+			tpvView = *view
+		}
+		pollData.Tpv = append(pollData.Tpv, w.tpvReport(*point, tpvView))
+	}
+	if view != nil {
+		pollData.Sky = append(pollData.Sky, w.skyReport(*view, pollData.Time))
+	}
+
+	return w.encoder.Encode(pollData)
+}
+
+// WriteRecordedPoll writes the POLL response with the replayed TPV and SKY reports as is, an empty one is left out
+func (w *Writer) WriteRecordedPoll(at time.Time, tpvLine, skyLine string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pollData := recordedPoll{
+		Class: "POLL",
+		Time:  at.UTC(),
+		Tpv:   make([]json.RawMessage, 0, 1),
+		Sky:   make([]json.RawMessage, 0, 1),
+	}
+	if tpvLine != "" {
+		pollData.Active = 1
+		pollData.Tpv = append(pollData.Tpv, json.RawMessage(tpvLine))
+	}
+	if skyLine != "" {
+		pollData.Sky = append(pollData.Sky, json.RawMessage(skyLine))
+	}
+
+	return w.encoder.Encode(pollData)
+}
+
+// WriteNMEA writes already encoded sentences as is, the same way gpsd passes them through from an NMEA device
+func (w *Writer) WriteNMEA(sentences []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, sentence := range sentences {
+		if _, err := io.WriteString(w.upstream, sentence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRecorded writes the recorded report or NMEA sentence as is, terminated the same way as the generated ones
+func (w *Writer) WriteRecorded(line string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	terminator := "\n"
+	if !strings.HasPrefix(line, "{") {
+		terminator = "\r\n"
+	}
+	_, err := io.WriteString(w.upstream, line+terminator)
+	return err
+}
+
+func (w *Writer) WriteStep(stepData stepRequest) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.encoder.Encode(stepData)
+}
+
+func (w *Writer) WriteError(message string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.encoder.Encode(errorReport{Class: "ERROR", Message: message})
+}
+
+// tpvReport fills the enabled TPV fields the fix mode allows: no position without a fix and no altitude with a 2D
+// fix. The error estimates are only reported when the DOPs are known.
+func (w *Writer) tpvReport(point route.Point, view sky.View) tpv {
+	mode := point.FixMode(w.config.TpvMode)
+	enabled := func(field string, minMode uint) bool {
+		return w.tpvFields[field] && mode >= minMode
+	}
+
+	report := w.tpv
+	report.Mode = mode
+	report.Time = point.Time.UTC()
+
+	status := w.config.TpvStatus
+	if point.Status != 0 {
+		status = point.Status
+	}
+	if mode < 2 {
+		status = 0
+	}
+	altHAE := point.Elevation + point.GeoidSeparation
+	report.Status = optional(enabled("status", 0), status)
+	report.LeapSeconds = optional(enabled("leapseconds", 0), point.LeapSeconds)
+	report.Lat = optional(enabled("lat", 2), point.Lat)
+	report.Lon = optional(enabled("lon", 2), point.Lon)
+	report.AltHAE = optional(enabled("altHAE", 3), float64Fixed3(altHAE))
+	report.AltMSL = optional(enabled("altMSL", 3), float64Fixed3(point.Elevation))
+	report.Alt = optional(enabled("alt", 3), float64Fixed3(point.Elevation))
+	report.GeoidSep = optional(enabled("geoidSep", 3), float64Fixed3(point.GeoidSeparation))
+	report.Track = optional(enabled("track", 2), float64Fixed3(point.Track))
+	report.MagTrack = optional(enabled("magtrack", 2), float64Fixed3(magneticTrack(point.Track, point.MagneticVariation)))
+	report.MagVar = optional(enabled("magvar", 2), float64Fixed1(point.MagneticVariation))
+	report.Speed = optional(enabled("speed", 2), float64Fixed3(point.Speed))
+	report.Climb = optional(enabled("climb", 3), float64Fixed3(point.Climb))
+
+	x, y, z := wgs84.ToECEF(point.Lat, point.Lon, altHAE)
+	trackRad := wgs84.DegreesToRadians(point.Track)
+	vx, vy, vz := wgs84.FromENU(point.Lat, point.Lon, point.Speed*math.Sin(trackRad), point.Speed*math.Cos(trackRad), point.Climb)
+	report.EcefX = optional(enabled("ecefx", 3), float64Fixed2(x))
+	report.EcefY = optional(enabled("ecefy", 3), float64Fixed2(y))
+	report.EcefZ = optional(enabled("ecefz", 3), float64Fixed2(z))
+	report.EcefVx = optional(enabled("ecefvx", 3), float64Fixed2(vx))
+	report.EcefVy = optional(enabled("ecefvy", 3), float64Fixed2(vy))
+	report.EcefVz = optional(enabled("ecefvz", 3), float64Fixed2(vz))
+
+	if view.Dop.P > 0 {
+		uere := w.config.Uere
+		epx, epy, epv := view.Dop.X*uere, view.Dop.Y*uere, view.Dop.V*uere
+		if point.Epx > 0 {
+			// the noise layer knows the errors it has added better than the geometry
+			epx, epy, epv = point.Epx, point.Epy, point.Epv
+		}
+		eph := math.Hypot(epx, epy)
+		report.Ept = optional(enabled("ept", 2), float64Fixed3(timeError))
+		report.Epx = optional(enabled("epx", 2), float64Fixed3(epx))
+		report.Epy = optional(enabled("epy", 2), float64Fixed3(epy))
+		report.Epv = optional(enabled("epv", 3), float64Fixed3(epv))
+		report.Eph = optional(enabled("eph", 2), float64Fixed3(eph))
+		report.Sep = optional(enabled("sep", 3), float64Fixed3(math.Hypot(eph, epv)))
+		report.Eps = optional(enabled("eps", 2), float64Fixed3(view.Dop.H*velocityUere))
+		report.Epc = optional(enabled("epc", 3), float64Fixed3(view.Dop.V*velocityUere))
+		report.EcefPAcc = optional(enabled("ecefpAcc", 3), float64Fixed2(math.Hypot(eph, epv)))
+		report.EcefVAcc = optional(enabled("ecefvAcc", 3), float64Fixed2(view.Dop.P*velocityUere))
+	}
+
+	return report
+}
+
+// magneticTrack converts the true track to the magnetic one, magneticVariation is positive to the east
+func magneticTrack(track, magneticVariation float64) float64 {
+	return math.Mod(track-magneticVariation+360, 360)
+}
+
+func optional[T any](enabled bool, value T) *T {
+	if !enabled {
+		return nil
+	}
+	return &value
+}
+
+// WriteTimeOffset writes a TOFF (timing: true) or PPS (pps: true) report for the top of the current second
+// of the simulated time, the clock is the wall clock time it's received at
+func (w *Writer) WriteTimeOffset(class string, at, received time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	clock := received.UTC()
+	second := at.UTC().Truncate(time.Second)
+	precision := -1
+	if class == "PPS" {
+		precision = -20
+	}
+
+	return w.encoder.Encode(timeOffset{
+		Class:     class,
+		Device:    w.config.DevicePath,
+		RealSec:   second.Unix(),
+		RealNsec:  int64(second.Nanosecond()),
+		ClockSec:  clock.Unix(),
+		ClockNsec: int64(clock.Nanosecond()),
+		Precision: precision,
+	})
+}
+
+func (w *Writer) skyReport(view sky.View, at time.Time) skyReport {
+	report := skyReport{
+		Class:      "SKY",
+		Device:     w.config.DevicePath,
+		Time:       at.UTC(),
+		Xdop:       float64Fixed2(view.Dop.X),
+		Ydop:       float64Fixed2(view.Dop.Y),
+		Vdop:       float64Fixed2(view.Dop.V),
+		Tdop:       float64Fixed2(view.Dop.T),
+		Hdop:       float64Fixed2(view.Dop.H),
+		Gdop:       float64Fixed2(view.Dop.G),
+		Pdop:       float64Fixed2(view.Dop.P),
+		NSat:       optional(w.skyFields["nSat"], len(view.Satellites)),
+		USat:       optional(w.skyFields["uSat"], view.Used()),
+		Satellites: make([]skySatellite, 0, len(view.Satellites)),
+	}
+	for _, sat := range view.Satellites {
+		report.Satellites = append(report.Satellites, skySatellite{
+			PRN:    sat.PRN,
+			El:     float64Fixed1(sat.Elevation),
+			Az:     float64Fixed1(sat.Azimuth),
+			Ss:     float64Fixed1(sat.SNR),
+			Used:   sat.Used,
+			GnssId: optional(w.skyFields["gnssid"], sat.GnssId),
+			SvId:   optional(w.skyFields["svid"], sat.SvId),
+		})
+	}
 
-	return w.encoder.Encode(w.tpv)
+	return report
 }
@@ -0,0 +1,97 @@
+package gpsd
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+// tpvJson returns the TPV report of the point as the JSON fields
+func tpvJson(t *testing.T, writer *Writer, point route.Point, view sky.View) map[string]json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(writer.tpvReport(point, view))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestTpvReportFields(t *testing.T) {
+	always := []string{"class", "device", "mode", "time"}
+	errors2d := []string{"ept", "epx", "epy", "eph", "eps"}
+	position2d := []string{"lat", "lon", "track", "magtrack", "magvar", "speed"}
+	position3d := []string{"altHAE", "altMSL", "alt", "geoidSep", "climb", "ecefx", "ecefy", "ecefz", "ecefvx", "ecefvy", "ecefvz"}
+	errors3d := []string{"epv", "sep", "epc", "ecefpAcc", "ecefvAcc"}
+	dop := sky.View{Dop: sky.Dop{X: 1, Y: 1, V: 2, H: 1.4, P: 2.4}}
+
+	tests := []struct {
+		name   string
+		mode   uint
+		fields []string
+		view   sky.View
+		want   [][]string
+	}{
+		{"3D fix", 3, nil, dop, [][]string{always, {"status", "leapseconds"}, position2d, errors2d, position3d, errors3d}},
+		{"2D fix", 2, nil, dop, [][]string{always, {"status", "leapseconds"}, position2d, errors2d}},
+		{"no fix", 1, nil, dop, [][]string{always, {"status", "leapseconds"}}},
+		{"unknown DOPs", 3, nil, sky.View{}, [][]string{always, {"status", "leapseconds"}, position2d, position3d}},
+		{"selected fields", 3, []string{"lat", "lon", "epv"}, dop, [][]string{always, {"lat", "lon", "epv"}}},
+		{"no optional fields", 3, []string{}, dop, [][]string{always}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writer := NewWriter(nil, WriterConfig{DevicePath: DefaultVersionDevicePath, TpvMode: test.mode, TpvFields: test.fields, Uere: DefaultUere})
+			fields := tpvJson(t, writer, route.Point{Lat: 47.38, Lon: 8.44, Time: time.Unix(0, 0)}, test.view)
+			got := slices.Sorted(maps.Keys(fields))
+			want := slices.Sorted(slices.Values(slices.Concat(test.want...)))
+			if !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTpvReportValues(t *testing.T) {
+	writer := NewWriter(nil, WriterConfig{DevicePath: DefaultVersionDevicePath, TpvMode: 3, TpvStatus: DefaultTpvStatus, Uere: DefaultUere})
+	point := route.Point{
+		Lat: 47.38, Lon: 8.44, Elevation: 500, GeoidSeparation: 48, Track: 10, MagneticVariation: 15, Speed: 20,
+		Climb: -1.5, LeapSeconds: 18, Time: time.Date(2025, time.June, 13, 17, 29, 0, 250000000, time.FixedZone("CEST", 2*60*60)),
+	}
+	view := sky.View{Dop: sky.Dop{X: 0.6, Y: 0.8, V: 2, H: 1, P: 2.2}}
+
+	fields := tpvJson(t, writer, point, view)
+	want := map[string]string{
+		"status": "1", "mode": "3", "time": `"2025-06-13T15:29:00.25Z"`, "leapseconds": "18", "altHAE": "548.00", "altMSL": "500.00",
+		"geoidSep": "48.00", "track": "10.00", "magtrack": "355.00", "magvar": "15.0", "climb": "-1.50",
+		"epx": "3.00", "epy": "4.00", "eph": "5.00", "epv": "10.00", "eps": "0.25", "epc": "0.50", "ept": "0.01",
+	}
+	for field, value := range want {
+		if got := string(fields[field]); got != value {
+			t.Errorf("got %s %s, want %s", field, got, value)
+		}
+	}
+
+	// the noise layer reports its own errors, and the recorded fix its own status
+	point.Epx, point.Epy, point.Epv, point.Status = 6, 8, 1, 2
+	fields = tpvJson(t, writer, point, view)
+	for field, value := range map[string]string{"epx": "6.00", "epy": "8.00", "eph": "10.00", "epv": "1.00", "status": "2"} {
+		if got := string(fields[field]); got != value {
+			t.Errorf("with the noise errors got %s %s, want %s", field, got, value)
+		}
+	}
+
+	// no fix has no status whatever is recorded
+	point.Mode = 1
+	if fields = tpvJson(t, writer, point, view); string(fields["status"]) != "0" || string(fields["mode"]) != "1" {
+		t.Errorf("got status %s in mode %s, want 0 in 1", fields["status"], fields["mode"])
+	}
+}
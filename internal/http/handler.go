@@ -5,9 +5,13 @@ import (
 	"embed"
 	_ "embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/aokhrimenko/gpsd-simulator/internal/route"
 )
@@ -25,7 +29,9 @@ type routeRequest struct {
 		Lat float64 `json:"lat"`
 		Lon float64 `json:"lng"`
 	} `json:"coordinates"`
-	MaxSpeed uint `json:"maxSpeed"`
+	MaxSpeed uint           `json:"maxSpeed"`
+	Dynamics route.Dynamics `json:"dynamics"`
+	Vehicle  string         `json:"vehicle"`
 }
 
 func (r *routeRequest) ToPoints() []route.Point {
@@ -53,11 +59,17 @@ func (s *Server) publicHandler() http.Handler {
 }
 
 type sseMessageInitialRoute struct {
-	Type     string        `json:"type"`
-	Name     string        `json:"name"`
-	Distance float64       `json:"distance"`
-	MaxSpeed uint          `json:"maxSpeed"`
-	Points   []route.Point `json:"points"`
+	Type      string          `json:"type"`
+	Name      string          `json:"name"`
+	Distance  float64         `json:"distance"`
+	MaxSpeed  uint            `json:"maxSpeed"`
+	Points    []route.Point   `json:"points"`
+	Outages   []route.Outage  `json:"outages"`
+	Noise     *route.Noise    `json:"noise"`
+	Dynamics  *route.Dynamics `json:"dynamics"`
+	Vehicle   string          `json:"vehicle"`
+	End       string          `json:"end"`
+	TimeScale float64         `json:"timeScale"`
 }
 
 type sseMessageCurrentPoint struct {
@@ -65,7 +77,11 @@ type sseMessageCurrentPoint struct {
 	Lat    float64 `json:"lat"`
 	Lon    float64 `json:"lon"`
 	Speed  float64 `json:"speed"`
+	Mode   uint    `json:"mode"`
 	Status string  `json:"status"`
+	// Elapsed and Duration are the route progress in seconds
+	Elapsed  float64 `json:"elapsed"`
+	Duration float64 `json:"duration"`
 }
 
 type sseMessageGeneral struct {
@@ -165,8 +181,11 @@ func (s *Server) sseHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-		case update := <-updates:
-			s.routeCtrl.GetState()
+		case update, isOpen := <-updates:
+			if !isOpen {
+				s.log.Infof("HTTP: the simulation has ended, closing SSE stream to %s", r.RemoteAddr)
+				return
+			}
 			_, err = w.Write([]byte("data: "))
 			if err != nil {
 				return
@@ -175,6 +194,10 @@ func (s *Server) sseHandler(w http.ResponseWriter, r *http.Request) {
 			currentPointMessage.Lat = update.Lat
 			currentPointMessage.Lon = update.Lon
 			currentPointMessage.Speed = update.Speed
+			currentPointMessage.Mode = update.Mode
+			elapsed, duration := s.routeCtrl.Progress()
+			currentPointMessage.Elapsed = elapsed.Seconds()
+			currentPointMessage.Duration = duration.Seconds()
 
 			err = json.NewEncoder(w).Encode(currentPointMessage)
 			if err != nil {
@@ -199,6 +222,12 @@ func (s *Server) writeInitialRoute(w http.ResponseWriter) error {
 	initialRouteMessage.Distance = currentRoute.Distance
 	initialRouteMessage.Points = currentRoute.Points
 	initialRouteMessage.MaxSpeed = currentRoute.MaxSpeed
+	initialRouteMessage.Outages = currentRoute.Outages
+	initialRouteMessage.Noise = currentRoute.Noise
+	initialRouteMessage.Dynamics = currentRoute.Dynamics
+	initialRouteMessage.Vehicle = currentRoute.Vehicle
+	initialRouteMessage.End = currentRoute.End
+	initialRouteMessage.TimeScale = s.routeCtrl.TimeScale()
 	_, err := w.Write([]byte("data: "))
 	if err != nil {
 		return err
@@ -217,7 +246,7 @@ func (s *Server) runHandler(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (s *Server) stopHandler(w http.ResponseWriter, _ *http.Request) {
-	s.routeCtrl.UpdateRoute("", 0, []route.Point{})
+	s.routeCtrl.UpdateRoute("", "", 0, route.Dynamics{}, []route.Point{})
 	s.sseBroadcast(sseMessageTypeRouteDeleted)
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -229,9 +258,19 @@ func (s *Server) saveRoute(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err = request.Dynamics.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Vehicle != "" {
+		if _, err = route.LookupVehicle(request.Vehicle); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 	points := request.ToPoints()
-	s.log.Infof("HTTP: Saving route Name=%s, Distance=%.2f, MaxSpeed=%d, Points=%d", request.Name, request.Distance, request.MaxSpeed, len(points))
-	s.routeCtrl.UpdateRoute(request.Name, request.MaxSpeed, points)
+	s.log.Infof("HTTP: Saving route Name=%s, Distance=%.2f, MaxSpeed=%d, Vehicle=%s, Points=%d", request.Name, request.Distance, request.MaxSpeed, request.Vehicle, len(points))
+	s.routeCtrl.UpdateRoute(request.Name, request.Vehicle, request.MaxSpeed, request.Dynamics, points)
 	s.sseBroadcast(sseMessageTypeInitialRoute)
 	w.WriteHeader(http.StatusCreated)
 }
@@ -249,12 +288,142 @@ func (s *Server) setRoute(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+var routeContentTypes = map[string]string{
+	"gpx": "application/gpx+xml",
+	"kml": "application/vnd.google-earth.kml+xml",
+	"kmz": "application/vnd.google-earth.kmz",
+}
+
+// getRoute returns the route file, or with ?format=gpx, kml or kmz the route as a track in that format
 func (s *Server) getRoute(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	format := r.URL.Query().Get("format")
 	routeCopy := s.routeCtrl.GetRoute()
-	err := json.NewEncoder(w).Encode(routeCopy)
+	var buf bytes.Buffer
+	err := s.routeCtrl.WriteRoute(&buf, routeCopy, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contentType, ok := routeContentTypes[format]
+	if !ok {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (s *Server) setOutages(w http.ResponseWriter, r *http.Request) {
+	var outages []route.Outage
+	err := json.NewDecoder(r.Body).Decode(&outages)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = s.routeCtrl.SetRouteOutages(outages); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sseBroadcast(sseMessageTypeInitialRoute)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) setNoise(w http.ResponseWriter, r *http.Request) {
+	var noise route.Noise
+	err := json.NewDecoder(r.Body).Decode(&noise)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = s.routeCtrl.SetRouteNoise(noise); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sseBroadcast(sseMessageTypeInitialRoute)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) setEnd(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		End string `json:"end"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = s.routeCtrl.SetRouteEnd(request.End); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sseBroadcast(sseMessageTypeInitialRoute)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) setTimeScale(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Scale float64 `json:"scale"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = s.routeCtrl.SetTimeScale(request.Scale); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sseBroadcast(sseMessageTypeInitialRoute)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// seekRequest sets one of the positions to move the route to
+type seekRequest struct {
+	Index    *int     `json:"index"`
+	Distance *float64 `json:"distance"`
+	Time     *float64 `json:"time"`
+	Lat      *float64 `json:"lat"`
+	Lon      *float64 `json:"lon"`
+}
+
+func (s *Server) seek(w http.ResponseWriter, r *http.Request) {
+	var request seekRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case request.Index != nil:
+		err = s.routeCtrl.SeekIndex(*request.Index)
+	case request.Distance != nil:
+		err = s.routeCtrl.SeekDistance(*request.Distance)
+	case request.Time != nil:
+		err = s.routeCtrl.SeekTime(time.Duration(*request.Time * float64(time.Second)))
+	case request.Lat != nil && request.Lon != nil:
+		err = s.routeCtrl.SeekNearest(*request.Lat, *request.Lon)
+	default:
+		err = errors.New("one of index, distance, time or lat and lon is required")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// step switches the route to the stepping mode and sends the next count points, one by default
+func (s *Server) step(w http.ResponseWriter, r *http.Request) {
+	count := 1
+	if value := r.URL.Query().Get("count"); value != "" {
+		var err error
+		if count, err = strconv.Atoi(value); err != nil {
+			http.Error(w, fmt.Sprintf("invalid step count %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.routeCtrl.Step(count); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
 }
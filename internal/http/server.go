@@ -35,6 +35,12 @@ func NewServer(ctx context.Context, port uint, log logger.Logger, routeCtrl *rou
 	mux.HandleFunc("POST /route", server.saveRoute)
 	mux.HandleFunc("POST /route/set", server.setRoute)
 	mux.HandleFunc("GET /route", server.getRoute)
+	mux.HandleFunc("POST /route/outages", server.setOutages)
+	mux.HandleFunc("POST /route/noise", server.setNoise)
+	mux.HandleFunc("POST /route/end", server.setEnd)
+	mux.HandleFunc("POST /route/timescale", server.setTimeScale)
+	mux.HandleFunc("POST /route/seek", server.seek)
+	mux.HandleFunc("POST /route/step", server.step)
 	mux.HandleFunc("/route/run", server.runHandler)
 	mux.HandleFunc("/route/stop", server.stopHandler)
 	mux.HandleFunc("/events", server.sseHandler)
@@ -0,0 +1,200 @@
+package nmea
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+const (
+	TalkerGPS     = "GP"
+	TalkerGLONASS = "GL"
+	TalkerGalileo = "GA"
+	TalkerBeiDou  = "GB"
+	TalkerGNSS    = "GN"
+
+	SentenceGGA = "GGA"
+	SentenceRMC = "RMC"
+	SentenceVTG = "VTG"
+	SentenceGSA = "GSA"
+	SentenceGSV = "GSV"
+	SentenceGLL = "GLL"
+	SentenceZDA = "ZDA"
+
+	DefaultTalker         = TalkerGPS
+	DefaultSatellitesUsed = 8
+	DefaultDop            = 1.0
+
+	knotsPerMeterPerSecond = 1.943844
+	kmhPerMeterPerSecond   = 3.6
+)
+
+var DefaultSentences = []string{SentenceRMC, SentenceGGA, SentenceGSA, SentenceGSV, SentenceVTG, SentenceGLL, SentenceZDA}
+
+type Config struct {
+	Talker    string
+	Sentences []string
+}
+
+func (c Config) Validate() error {
+	switch c.Talker {
+	case TalkerGPS, TalkerGLONASS, TalkerGNSS:
+	default:
+		return fmt.Errorf("unsupported NMEA talker ID %q, expected one of %s, %s, %s", c.Talker, TalkerGPS, TalkerGNSS, TalkerGLONASS)
+	}
+	for _, sentence := range c.Sentences {
+		if _, ok := sentenceEncoders[strings.ToUpper(sentence)]; !ok {
+			return fmt.Errorf("unsupported NMEA sentence %q", sentence)
+		}
+	}
+	return nil
+}
+
+// Fix is everything a receiver knows at a single epoch. The sky view is optional: when it's not
+// known, the sentences are filled with DefaultSatellitesUsed and DefaultDop.
+type Fix struct {
+	Point route.Point
+	Time  time.Time
+	Mode  uint
+	Sky   sky.View
+}
+
+func (f Fix) hasPosition() bool {
+	return f.Mode >= 2
+}
+
+// satellitesUsed counts the used satellites of the talker constellations
+func (e *Encoder) satellitesUsed(fix Fix) int {
+	if len(fix.Sky.Satellites) == 0 {
+		if fix.hasPosition() {
+			return DefaultSatellitesUsed
+		}
+		return 0
+	}
+	used := 0
+	for _, sat := range fix.Sky.Satellites {
+		if sat.Used && e.reports(sat) {
+			used++
+		}
+	}
+	return used
+}
+
+func dopOrDefault(dop float64) float64 {
+	if dop <= 0 {
+		return DefaultDop
+	}
+	return dop
+}
+
+type sentenceEncoder func(e *Encoder, fix Fix) []string
+
+var sentenceEncoders = map[string]sentenceEncoder{
+	SentenceGGA: (*Encoder).gga,
+	SentenceRMC: (*Encoder).rmc,
+	SentenceVTG: (*Encoder).vtg,
+	SentenceGSA: (*Encoder).gsa,
+	SentenceGSV: (*Encoder).gsv,
+	SentenceGLL: (*Encoder).gll,
+	SentenceZDA: (*Encoder).zda,
+}
+
+func NewEncoder(config Config) (*Encoder, error) {
+	if config.Talker == "" {
+		config.Talker = DefaultTalker
+	}
+	if len(config.Sentences) == 0 {
+		config.Sentences = DefaultSentences
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	encoders := make([]sentenceEncoder, 0, len(config.Sentences))
+	for _, sentence := range config.Sentences {
+		encoders = append(encoders, sentenceEncoders[strings.ToUpper(sentence)])
+	}
+
+	return &Encoder{config: config, encoders: encoders}, nil
+}
+
+type Encoder struct {
+	config   Config
+	encoders []sentenceEncoder
+}
+
+// Encode returns the configured sentences for the fix, each one terminated with CRLF
+func (e *Encoder) Encode(fix Fix) []string {
+	sentences := make([]string, 0, len(e.encoders)+3)
+	for _, encoder := range e.encoders {
+		sentences = append(sentences, encoder(e, fix)...)
+	}
+	return sentences
+}
+
+// sentence builds `$<talker><type>,<fields>*<checksum>\r\n`
+func sentence(talker, sentenceType string, fields ...string) string {
+	body := talker + sentenceType + "," + strings.Join(fields, ",")
+	return fmt.Sprintf("$%s*%02X\r\n", body, Checksum(body))
+}
+
+// Checksum is the XOR of all the characters between `$` and `*`
+func Checksum(body string) byte {
+	var checksum byte
+	for i := 0; i < len(body); i++ {
+		checksum ^= body[i]
+	}
+	return checksum
+}
+
+// gnssForTalker is the constellation of a single system talker, -1 for the GNSS talker reporting all of them
+func gnssForTalker(talker string) int {
+	switch talker {
+	case TalkerGNSS:
+		return -1
+	case TalkerGLONASS:
+		return sky.GnssIdGLONASS
+	case TalkerGalileo:
+		return sky.GnssIdGalileo
+	case TalkerBeiDou:
+		return sky.GnssIdBeiDou
+	default:
+		return sky.GnssIdGPS
+	}
+}
+
+// reports tells whether the satellite belongs to the constellations of the talker
+func (e *Encoder) reports(sat sky.Satellite) bool {
+	gnssId := gnssForTalker(e.config.Talker)
+	return gnssId < 0 || sat.GnssId == gnssId
+}
+
+func talkerForGnss(gnssId int) string {
+	switch gnssId {
+	case sky.GnssIdGLONASS:
+		return TalkerGLONASS
+	case sky.GnssIdGalileo:
+		return TalkerGalileo
+	case sky.GnssIdBeiDou:
+		return TalkerBeiDou
+	default:
+		return TalkerGPS
+	}
+}
+
+// systemIdForGnss is the NMEA 4.10 GNSS system ID of GSA
+func systemIdForGnss(gnssId int) string {
+	switch gnssId {
+	case sky.GnssIdGLONASS:
+		return "2"
+	case sky.GnssIdGalileo:
+		return "3"
+	case sky.GnssIdBeiDou:
+		return "4"
+	default:
+		return "1"
+	}
+}
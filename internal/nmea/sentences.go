@@ -0,0 +1,237 @@
+package nmea
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+// $GNGGA,172900.34,4722.91058,N,00826.89476,E,1,02,1.0,575.0,M,0.0,M,,*42
+func (e *Encoder) gga(fix Fix) []string {
+	lat, ns, lon, ew := formatLatLon(fix)
+	quality := "0"
+	alt, altUnit := "", ""
+	separation, separationUnit := "", ""
+	if fix.hasPosition() {
+		quality = ggaQuality(fix.Point.Status)
+	}
+	if fix.Mode >= 3 {
+		alt, altUnit = formatFloat(fix.Point.Elevation, 1), "M"
+		separation, separationUnit = formatFloat(fix.Point.GeoidSeparation, 1), "M"
+	}
+
+	return []string{sentence(e.config.Talker, SentenceGGA,
+		formatTime(fix.Time), lat, ns, lon, ew, quality,
+		fmt.Sprintf("%02d", e.satellitesUsed(fix)),
+		formatFloat(dopOrDefault(fix.Sky.Dop.H), 1),
+		alt, altUnit, separation, separationUnit, "", "",
+	)}
+}
+
+// ggaQuality maps the TPV status to the GGA fix quality
+func ggaQuality(status uint) string {
+	switch status {
+	case 2:
+		return "2"
+	case 3:
+		return "4"
+	case 4:
+		return "5"
+	case 5:
+		return "6"
+	}
+	return "1"
+}
+
+// $GNRMC,172900.34,A,4722.91058,N,00826.89476,E,29.702,91.1,130625,,,A*40
+func (e *Encoder) rmc(fix Fix) []string {
+	lat, ns, lon, ew := formatLatLon(fix)
+	status, modeIndicator := "V", "N"
+	speed, track, magvar, magvarEw := "", "", "", ""
+	if fix.hasPosition() {
+		status, modeIndicator = "A", "A"
+		speed = formatFloat(fix.Point.Speed*knotsPerMeterPerSecond, 3)
+		track = formatFloat(fix.Point.Track, 1)
+		// the unknown variation is left empty the same way receivers without a magnetic model do
+		if fix.Point.MagneticVariation != 0 {
+			magvar, magvarEw = formatFloat(math.Abs(fix.Point.MagneticVariation), 1), "E"
+			if fix.Point.MagneticVariation < 0 {
+				magvarEw = "W"
+			}
+		}
+	}
+
+	return []string{sentence(e.config.Talker, SentenceRMC,
+		formatTime(fix.Time), status, lat, ns, lon, ew, speed, track,
+		fix.Time.UTC().Format("020106"), magvar, magvarEw, modeIndicator,
+	)}
+}
+
+// $GNVTG,91.1,T,,M,29.702,N,55.008,K,A*2C
+func (e *Encoder) vtg(fix Fix) []string {
+	if !fix.hasPosition() {
+		return []string{sentence(e.config.Talker, SentenceVTG, "", "T", "", "M", "", "N", "", "K", "N")}
+	}
+
+	return []string{sentence(e.config.Talker, SentenceVTG,
+		formatFloat(fix.Point.Track, 1), "T", "", "M",
+		formatFloat(fix.Point.Speed*knotsPerMeterPerSecond, 3), "N",
+		formatFloat(fix.Point.Speed*kmhPerMeterPerSecond, 3), "K", "A",
+	)}
+}
+
+// $GNGSA,A,3,01,03,,,,,,,,,,,1.8,1.0,1.5,1*22
+// The GNSS talker reports a sentence per constellation with the NMEA 4.10 system ID, as the Galileo and BeiDou
+// satellite numbers overlap the GPS ones.
+func (e *Encoder) gsa(fix Fix) []string {
+	mode := fix.Mode
+	if mode < 1 {
+		mode = 1
+	}
+	groups := make(map[string][]string)
+	order := make([]string, 0, 4)
+	for _, sat := range fix.Sky.Satellites {
+		if !sat.Used || !e.reports(sat) {
+			continue
+		}
+		systemId := ""
+		if e.config.Talker == TalkerGNSS {
+			systemId = systemIdForGnss(sat.GnssId)
+		}
+		if _, ok := groups[systemId]; !ok {
+			order = append(order, systemId)
+		}
+		if len(groups[systemId]) < 12 {
+			groups[systemId] = append(groups[systemId], fmt.Sprintf("%02d", sat.NmeaId()))
+		}
+	}
+	if len(order) == 0 {
+		order = append(order, "")
+	}
+
+	sentences := make([]string, 0, len(order))
+	for _, systemId := range order {
+		prns := make([]string, 12)
+		copy(prns, groups[systemId])
+		fields := []string{"A", fmt.Sprintf("%d", mode)}
+		fields = append(fields, prns...)
+		fields = append(fields,
+			formatFloat(dopOrDefault(fix.Sky.Dop.P), 1),
+			formatFloat(dopOrDefault(fix.Sky.Dop.H), 1),
+			formatFloat(dopOrDefault(fix.Sky.Dop.V), 1),
+		)
+		if systemId != "" {
+			fields = append(fields, systemId)
+		}
+		sentences = append(sentences, sentence(e.config.Talker, SentenceGSA, fields...))
+	}
+
+	return sentences
+}
+
+// $GPGSV,1,1,02,01,45,123,42,03,30,045,38*77
+// Satellites are reported per constellation, each group with its own talker ID.
+func (e *Encoder) gsv(fix Fix) []string {
+	groups := make(map[string][]sky.Satellite)
+	order := make([]string, 0, 4)
+	for _, sat := range fix.Sky.Satellites {
+		if !e.reports(sat) {
+			continue
+		}
+		talker := talkerForGnss(sat.GnssId)
+		if _, ok := groups[talker]; !ok {
+			order = append(order, talker)
+		}
+		groups[talker] = append(groups[talker], sat)
+	}
+	if len(order) == 0 {
+		return []string{sentence(e.config.Talker, SentenceGSV, "1", "1", "00")}
+	}
+
+	sentences := make([]string, 0, len(fix.Sky.Satellites)/4+len(order))
+	for _, talker := range order {
+		sats := groups[talker]
+		total := (len(sats) + 3) / 4
+		for message := 0; message < total; message++ {
+			fields := []string{fmt.Sprintf("%d", total), fmt.Sprintf("%d", message+1), fmt.Sprintf("%02d", len(sats))}
+			for i := message * 4; i < len(sats) && i < (message+1)*4; i++ {
+				sat := sats[i]
+				snr := ""
+				if sat.SNR > 0 {
+					snr = fmt.Sprintf("%02.0f", sat.SNR)
+				}
+				fields = append(fields,
+					fmt.Sprintf("%02d", sat.NmeaId()),
+					fmt.Sprintf("%02.0f", sat.Elevation),
+					fmt.Sprintf("%03.0f", sat.Azimuth),
+					snr,
+				)
+			}
+			sentences = append(sentences, sentence(talker, SentenceGSV, fields...))
+		}
+	}
+
+	return sentences
+}
+
+// $GNGLL,4722.91058,N,00826.89476,E,172900.34,A,A*73
+func (e *Encoder) gll(fix Fix) []string {
+	lat, ns, lon, ew := formatLatLon(fix)
+	status, modeIndicator := "V", "N"
+	if fix.hasPosition() {
+		status, modeIndicator = "A", "A"
+	}
+
+	return []string{sentence(e.config.Talker, SentenceGLL, lat, ns, lon, ew, formatTime(fix.Time), status, modeIndicator)}
+}
+
+// $GNZDA,172900.34,13,06,2025,00,00*73
+func (e *Encoder) zda(fix Fix) []string {
+	t := fix.Time.UTC()
+	return []string{sentence(e.config.Talker, SentenceZDA,
+		formatTime(t), fmt.Sprintf("%02d", t.Day()), fmt.Sprintf("%02d", int(t.Month())), fmt.Sprintf("%04d", t.Year()), "00", "00",
+	)}
+}
+
+func formatTime(t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("%02d%02d%02d.%02d", t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/int(10*time.Millisecond))
+}
+
+// formatLatLon converts decimal degrees to the NMEA ddmm.mmmmm/dddmm.mmmmm notation, empty without a position fix
+func formatLatLon(fix Fix) (lat, ns, lon, ew string) {
+	if !fix.hasPosition() {
+		return "", "", "", ""
+	}
+
+	ns, ew = "N", "E"
+	latitude, longitude := fix.Point.Lat, fix.Point.Lon
+	if latitude < 0 {
+		ns = "S"
+		latitude = -latitude
+	}
+	if longitude < 0 {
+		ew = "W"
+		longitude = -longitude
+	}
+
+	latDeg, latMin := degreesMinutes(latitude)
+	lonDeg, lonMin := degreesMinutes(longitude)
+	lat = fmt.Sprintf("%02d%08.5f", latDeg, latMin)
+	lon = fmt.Sprintf("%03d%08.5f", lonDeg, lonMin)
+
+	return lat, ns, lon, ew
+}
+
+// degreesMinutes rounds the minutes before splitting, so 59.999999' becomes the next degree instead of 60.00000'
+func degreesMinutes(value float64) (int, float64) {
+	minutes := math.Round(value*60*1e5) / 1e5
+	degrees := math.Floor(minutes / 60)
+	return int(degrees), minutes - degrees*60
+}
+
+func formatFloat(value float64, precision int) string {
+	return fmt.Sprintf("%.*f", precision, value)
+}
@@ -0,0 +1,239 @@
+package nmea
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+var testTime = time.Date(2025, time.June, 13, 17, 29, 0, 340000000, time.UTC)
+
+func testFix() Fix {
+	return Fix{
+		Point: route.Point{Lat: 47.38, Lon: -8.44, Elevation: 575, GeoidSeparation: 48.04, Speed: 15.28, Track: 91.14, Status: 2},
+		Time:  testTime,
+		Mode:  3,
+		Sky: sky.View{Satellites: []sky.Satellite{
+			{PRN: 1, GnssId: sky.GnssIdGPS, SvId: 1, Elevation: 45, Azimuth: 123, SNR: 42, Used: true},
+			{PRN: 3, GnssId: sky.GnssIdGPS, SvId: 3, Elevation: 30, Azimuth: 45, SNR: 38, Used: true},
+			{PRN: 7, GnssId: sky.GnssIdGPS, SvId: 7, Elevation: 5, Azimuth: 7},
+			{PRN: 12, GnssId: sky.GnssIdGPS, SvId: 12, Elevation: 10, Azimuth: 300, SNR: 20},
+			{PRN: 65, GnssId: sky.GnssIdGLONASS, SvId: 1, Elevation: 50, Azimuth: 100, SNR: 40, Used: true},
+			{PRN: 305, GnssId: sky.GnssIdGalileo, SvId: 5, Elevation: 35, Azimuth: 150, SNR: 41, Used: true},
+		}, Dop: sky.Dop{P: 1.8, H: 1.04, V: 1.5}},
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		body string
+		want byte
+	}{
+		{"GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,", 0x47},
+		{"GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W", 0x6A},
+		{"", 0},
+	}
+	for _, test := range tests {
+		if got := Checksum(test.body); got != test.want {
+			t.Errorf("%q: got %02X, want %02X", test.body, got, test.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	fix := testFix()
+	noSky := fix
+	noSky.Sky = sky.View{}
+	fix2d := noSky
+	fix2d.Mode = 2
+	noFix := noSky
+	noFix.Mode = 1
+	gpsOnly := fix
+	gpsOnly.Sky.Satellites = fix.Sky.Satellites[:4]
+
+	tests := []struct {
+		name   string
+		talker string
+		fix    Fix
+		want   []string
+	}{
+		{"3D fix", TalkerGNSS, fix, []string{
+			"$GNRMC,172900.34,A,4722.80000,N,00826.40000,W,29.702,91.1,130625,,,A*5F\r\n",
+			"$GNGGA,172900.34,4722.80000,N,00826.40000,W,2,04,1.0,575.0,M,48.0,M,,*64\r\n",
+			"$GNGSA,A,3,01,03,,,,,,,,,,,1.8,1.0,1.5,1*3F\r\n",
+			"$GNGSA,A,3,65,,,,,,,,,,,,1.8,1.0,1.5,2*3D\r\n",
+			"$GNGSA,A,3,05,,,,,,,,,,,,1.8,1.0,1.5,3*3A\r\n",
+			"$GPGSV,1,1,04,01,45,123,42,03,30,045,38,07,05,007,,12,10,300,20*77\r\n",
+			"$GLGSV,1,1,01,65,50,100,40*57\r\n",
+			"$GAGSV,1,1,01,05,35,150,41*5B\r\n",
+			"$GNVTG,91.1,T,,M,29.702,N,55.008,K,A*2C\r\n",
+			"$GNGLL,4722.80000,N,00826.40000,W,172900.34,A,A*6C\r\n",
+			"$GNZDA,172900.34,13,06,2025,00,00*73\r\n",
+		}},
+		{"GPS only", TalkerGPS, fix, []string{
+			"$GPRMC,172900.34,A,4722.80000,N,00826.40000,W,29.702,91.1,130625,,,A*41\r\n",
+			"$GPGGA,172900.34,4722.80000,N,00826.40000,W,2,02,1.0,575.0,M,48.0,M,,*7C\r\n",
+			"$GPGSA,A,3,01,03,,,,,,,,,,,1.8,1.0,1.5*3C\r\n",
+			"$GPGSV,1,1,04,01,45,123,42,03,30,045,38,07,05,007,,12,10,300,20*77\r\n",
+			"$GPVTG,91.1,T,,M,29.702,N,55.008,K,A*32\r\n",
+			"$GPGLL,4722.80000,N,00826.40000,W,172900.34,A,A*72\r\n",
+			"$GPZDA,172900.34,13,06,2025,00,00*6D\r\n",
+		}},
+		{"GLONASS only", TalkerGLONASS, fix, []string{
+			"$GLRMC,172900.34,A,4722.80000,N,00826.40000,W,29.702,91.1,130625,,,A*5D\r\n",
+			"$GLGGA,172900.34,4722.80000,N,00826.40000,W,2,01,1.0,575.0,M,48.0,M,,*63\r\n",
+			"$GLGSA,A,3,65,,,,,,,,,,,,1.8,1.0,1.5*21\r\n",
+			"$GLGSV,1,1,01,65,50,100,40*57\r\n",
+			"$GLVTG,91.1,T,,M,29.702,N,55.008,K,A*2E\r\n",
+			"$GLGLL,4722.80000,N,00826.40000,W,172900.34,A,A*6E\r\n",
+			"$GLZDA,172900.34,13,06,2025,00,00*71\r\n",
+		}},
+		{"GLONASS without GLONASS satellites", TalkerGLONASS, gpsOnly, []string{
+			"$GLRMC,172900.34,A,4722.80000,N,00826.40000,W,29.702,91.1,130625,,,A*5D\r\n",
+			"$GLGGA,172900.34,4722.80000,N,00826.40000,W,2,00,1.0,575.0,M,48.0,M,,*62\r\n",
+			"$GLGSA,A,3,,,,,,,,,,,,,1.8,1.0,1.5*22\r\n",
+			"$GLGSV,1,1,00*65\r\n",
+			"$GLVTG,91.1,T,,M,29.702,N,55.008,K,A*2E\r\n",
+			"$GLGLL,4722.80000,N,00826.40000,W,172900.34,A,A*6E\r\n",
+			"$GLZDA,172900.34,13,06,2025,00,00*71\r\n",
+		}},
+		{"2D fix without a sky view", TalkerGPS, fix2d, []string{
+			"$GPRMC,172900.34,A,4722.80000,N,00826.40000,W,29.702,91.1,130625,,,A*41\r\n",
+			"$GPGGA,172900.34,4722.80000,N,00826.40000,W,2,08,1.0,,,,,,*4D\r\n",
+			"$GPGSA,A,2,,,,,,,,,,,,,1.0,1.0,1.0*32\r\n",
+			"$GPGSV,1,1,00*79\r\n",
+			"$GPVTG,91.1,T,,M,29.702,N,55.008,K,A*32\r\n",
+			"$GPGLL,4722.80000,N,00826.40000,W,172900.34,A,A*72\r\n",
+			"$GPZDA,172900.34,13,06,2025,00,00*6D\r\n",
+		}},
+		{"no fix", TalkerGPS, noFix, []string{
+			"$GPRMC,172900.34,V,,,,,,,130625,,,N*74\r\n",
+			"$GPGGA,172900.34,,,,,0,00,1.0,,,,,,*6D\r\n",
+			"$GPGSA,A,1,,,,,,,,,,,,,1.0,1.0,1.0*31\r\n",
+			"$GPGSV,1,1,00*79\r\n",
+			"$GPVTG,,T,,M,,N,,K,N*2C\r\n",
+			"$GPGLL,,,,,172900.34,V,N*40\r\n",
+			"$GPZDA,172900.34,13,06,2025,00,00*6D\r\n",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder, err := NewEncoder(Config{Talker: test.talker})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := encoder.Encode(test.fix)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got\n%q\nwant\n%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGsvPaging(t *testing.T) {
+	fix := testFix()
+	fix.Sky.Satellites = nil
+	for prn := 1; prn <= 9; prn++ {
+		fix.Sky.Satellites = append(fix.Sky.Satellites, sky.Satellite{PRN: prn, SvId: prn, Elevation: 20, Azimuth: float64(prn * 10), SNR: 30})
+	}
+	encoder, err := NewEncoder(Config{Sentences: []string{"gsv"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"$GPGSV,3,1,09,01,20,010,30,02,20,020,30,03,20,030,30,04,20,040,30*72\r\n",
+		"$GPGSV,3,2,09,05,20,050,30,06,20,060,30,07,20,070,30,08,20,080,30*71\r\n",
+		"$GPGSV,3,3,09,09,20,090,30*41\r\n",
+	}
+	if got := encoder.Encode(fix); !slices.Equal(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRmcMagneticVariation(t *testing.T) {
+	encoder, err := NewEncoder(Config{Sentences: []string{"rmc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		variation float64
+		mode      uint
+		want      string
+	}{
+		{"west", -2.94, 3, "$GPRMC,172900.34,A,4722.80000,N,00826.40000,W,29.702,91.1,130625,2.9,W,A*33\r\n"},
+		{"east", 2.96, 3, "$GPRMC,172900.34,A,4722.80000,N,00826.40000,W,29.702,91.1,130625,3.0,E,A*29\r\n"},
+		{"no fix", 2.96, 1, "$GPRMC,172900.34,V,,,,,,,130625,,,N*74\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fix := testFix()
+			fix.Point.MagneticVariation, fix.Mode = test.variation, test.mode
+			if got := encoder.Encode(fix); !slices.Equal(got, []string{test.want}) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	tests := []struct {
+		name           string
+		lat, lon       float64
+		wantLat, ns    string
+		wantLon, ew    string
+		fractionalTime time.Duration
+		wantTime       string
+	}{
+		{"origin", 0, 0, "0000.00000", "N", "00000.00000", "E", 0, "172900.00"},
+		{"minutes rounded up to the next degree", 47.9999999999, 179.9999999999, "4800.00000", "N", "18000.00000", "E", 999 * time.Millisecond, "172900.99"},
+		{"southern and western hemispheres", -33.8688, -151.2093, "3352.12800", "S", "15112.55800", "W", 5 * time.Millisecond, "172900.00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fix := Fix{Point: route.Point{Lat: test.lat, Lon: test.lon}, Mode: 2}
+			lat, ns, lon, ew := formatLatLon(fix)
+			if lat != test.wantLat || ns != test.ns || lon != test.wantLon || ew != test.ew {
+				t.Errorf("got %s,%s,%s,%s, want %s,%s,%s,%s", lat, ns, lon, ew, test.wantLat, test.ns, test.wantLon, test.ew)
+			}
+			at := time.Date(2025, time.June, 13, 19, 29, 0, 0, time.FixedZone("CEST", 2*60*60)).Add(test.fractionalTime)
+			if got := formatTime(at); got != test.wantTime {
+				t.Errorf("got time %s, want %s", got, test.wantTime)
+			}
+		})
+	}
+}
+
+func TestGgaQuality(t *testing.T) {
+	for status, want := range []string{"1", "1", "2", "4", "5", "6", "1"} {
+		if got := ggaQuality(uint(status)); got != want {
+			t.Errorf("status %d: got quality %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config Config
+		err    bool
+	}{
+		{Config{Talker: TalkerGNSS, Sentences: []string{"gga", "RMC"}}, false},
+		{Config{Talker: TalkerGalileo}, true},
+		{Config{Talker: TalkerGPS, Sentences: []string{"GGA", "TXT"}}, true},
+	}
+	for _, test := range tests {
+		if err := test.config.Validate(); (err != nil) != test.err {
+			t.Errorf("%+v: got error %v", test.config, err)
+		}
+	}
+	encoder, err := NewEncoder(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoder.config.Talker != DefaultTalker || len(encoder.encoders) != len(DefaultSentences) {
+		t.Errorf("got %+v, want the default talker and sentences", encoder.config)
+	}
+}
@@ -0,0 +1,150 @@
+package pty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+	"github.com/aokhrimenko/gpsd-simulator/internal/nmea"
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+// NewOutput creates the virtual serial device streaming NMEA sentences of the current route.
+// If link isn't empty, a symlink with this name pointing to the pseudo-terminal is created,
+// so the device path stays the same between the runs.
+func NewOutput(ctx context.Context, log logger.Logger, routeCtrl *route.Controller, nmeaConfig nmea.Config, skyModel sky.Model, mode uint, link string) (*Output, error) {
+	encoder, err := nmea.NewEncoder(nmeaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &Output{
+		log:       log,
+		routeCtrl: routeCtrl,
+		encoder:   encoder,
+		skyModel:  skyModel,
+		mode:      mode,
+		link:      link,
+		done:      make(chan struct{}),
+	}
+	output.ctx, output.cancel = context.WithCancel(ctx)
+
+	return output, nil
+}
+
+type Output struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	log       logger.Logger
+	routeCtrl *route.Controller
+	encoder   *nmea.Encoder
+	skyModel  sky.Model
+	terminal  *Terminal
+	mode      uint
+	link      string
+	done      chan struct{}
+}
+
+func (o *Output) Startup() (err error) {
+	o.terminal, err = Open()
+	if err != nil {
+		return err
+	}
+
+	if o.link != "" {
+		if err = createLink(o.terminal.Path, o.link); err != nil {
+			_ = o.terminal.Close()
+			return err
+		}
+		o.log.Infof("PTY: streaming NMEA to %s -> %s", o.link, o.terminal.Path)
+	} else {
+		o.log.Infof("PTY: streaming NMEA to %s", o.terminal.Path)
+	}
+
+	updates, unsubscribeFunc := o.routeCtrl.Subscribe()
+	go o.loop(updates, unsubscribeFunc)
+
+	return nil
+}
+
+func (o *Output) Shutdown() {
+	o.log.Info("PTY: shutting down the virtual serial device")
+	o.cancel()
+	<-o.done
+}
+
+// DevicePath is the path clients should open: the symlink if requested, otherwise the pseudo-terminal itself
+func (o *Output) DevicePath() string {
+	if o.link != "" {
+		return o.link
+	}
+	return o.terminal.Path
+}
+
+func (o *Output) loop(updates chan route.Point, unsubscribeFunc func()) {
+	defer func() {
+		defer close(o.done)
+		unsubscribeFunc()
+		if o.link != "" {
+			if err := os.Remove(o.link); err != nil {
+				o.log.Warnf("PTY: failed to remove %s: %v", o.link, err)
+			}
+		}
+		if err := o.terminal.Close(); err != nil {
+			o.log.Warnf("PTY: failed to close %s: %v", o.terminal.Path, err)
+		}
+	}()
+
+	for {
+		select {
+		case <-o.ctx.Done():
+			return
+		case point, isOpen := <-updates:
+			if !isOpen {
+				return
+			}
+			now := point.Time.UTC()
+			mode := point.FixMode(o.mode)
+			sentences := o.encoder.Encode(nmea.Fix{
+				Point: point,
+				Time:  now,
+				Mode:  mode,
+				Sky:   point.Sky(o.skyModel, mode),
+			})
+			// the epochs nobody reads in time are lost, like on a real serial line
+			_, err := o.terminal.Write([]byte(strings.Join(sentences, "")))
+			if errors.Is(err, errDropped) {
+				continue
+			}
+			if err != nil {
+				o.log.Errorf("PTY: write to %s failed on point %s: %v", o.terminal.Path, point, err)
+				return
+			}
+		}
+	}
+}
+
+// createLink replaces a stale symlink left by the previous run, but never a regular file
+func createLink(target, link string) error {
+	info, err := os.Lstat(link)
+	switch {
+	case err == nil && info.Mode()&fs.ModeSymlink == 0:
+		return fmt.Errorf("failed to create symlink %s: file exists and isn't a symlink", link)
+	case err == nil:
+		if err = os.Remove(link); err != nil {
+			return fmt.Errorf("failed to remove stale symlink %s: %w", link, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to check symlink %s: %w", link, err)
+	}
+
+	if err = os.Symlink(target, link); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", link, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package pty
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// errDropped tells the data was dropped because nobody has read the previous data in time
+var errDropped = errors.New("the pseudo-terminal buffer is full, the data is dropped")
+
+// Terminal is a pseudo-terminal pair. The simulator writes to the master side and the clients read the slave
+// side at Path, the same way they would read a GPS receiver on /dev/ttyUSB*.
+type Terminal struct {
+	Path   string
+	master int
+	slave  int
+}
+
+func Open() (*Terminal, error) {
+	master, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /dev/ptmx: %w", err)
+	}
+
+	unlock := int32(0)
+	if err = ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		_ = syscall.Close(master)
+		return nil, fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+
+	var number uint32
+	if err = ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); err != nil {
+		_ = syscall.Close(master)
+		return nil, fmt.Errorf("failed to get pseudo-terminal number: %w", err)
+	}
+	path := fmt.Sprintf("/dev/pts/%d", number)
+
+	// Keep the slave side open ourselves: the master doesn't fail while no client is attached,
+	// and the stale data could be drained from here when nobody reads it
+	slave, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		_ = syscall.Close(master)
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	if err = makeRaw(slave); err != nil {
+		_ = syscall.Close(slave)
+		_ = syscall.Close(master)
+		return nil, fmt.Errorf("failed to switch %s to raw mode: %w", path, err)
+	}
+
+	return &Terminal{Path: path, master: master, slave: slave}, nil
+}
+
+// Write never blocks: like a real serial line, the data nobody reads in time is lost. The data is written whole or
+// dropped with errDropped, so the reader never gets a part of a sentence.
+func (t *Terminal) Write(data []byte) (int, error) {
+	for retry := false; ; retry = true {
+		n, err := syscall.Write(t.master, data)
+		if n == len(data) {
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, syscall.EAGAIN) {
+			return 0, err
+		}
+		// the buffer is full, drop what nobody has read together with the part of the data just written
+		t.flush()
+		if retry {
+			return 0, errDropped
+		}
+	}
+}
+
+// flush drops the data waiting for the reader, including the data the kernel hasn't passed to it yet
+func (t *Terminal) flush() {
+	if err := ioctl(t.slave, tcflsh, syscall.TCIFLUSH); err != nil {
+		t.drain()
+	}
+}
+
+func (t *Terminal) Close() error {
+	return errors.Join(syscall.Close(t.slave), syscall.Close(t.master))
+}
+
+func (t *Terminal) drain() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.Read(t.slave, buf)
+		if n <= 0 || err != nil {
+			return
+		}
+	}
+}
+
+// tcflsh is the TCFLSH request of the asm-generic ioctls, the syscall package doesn't define it
+const tcflsh = 0x540B
+
+// makeRaw is cfmakeraw(3): no echo, no line editing and no CR/LF translation
+func makeRaw(fd int) error {
+	var termios syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); err != nil {
+		return err
+	}
+
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+
+	return ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&termios)))
+}
+
+func ioctl(fd int, request uint, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(request), arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
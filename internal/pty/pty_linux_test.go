@@ -0,0 +1,66 @@
+//go:build linux
+
+package pty
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestWriteWholeEpochs overflows the terminal nobody reads, every epoch must reach the reader whole or not at all
+func TestWriteWholeEpochs(t *testing.T) {
+	terminal, err := Open()
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	defer terminal.Close()
+
+	// about 1.3 KB, so the epochs don't align with the buffer sizes
+	sentence := "$GPGSV,4,1,16,01,45,123,42,03,30,045,38,07,60,200,45,12,10,300,20*70\r\n"
+	written := 0
+	for i := range 500 {
+		epoch := fmt.Sprintf("$EPOCH,%d\r\n%s$END,%d\r\n", i, strings.Repeat(sentence, 18), i)
+		n, err := terminal.Write([]byte(epoch))
+		if err != nil && !errors.Is(err, errDropped) {
+			t.Fatal(err)
+		}
+		if n != 0 && n != len(epoch) {
+			t.Fatalf("epoch %d: short write of %d bytes out of %d", i, n, len(epoch))
+		}
+		written += n
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	var received strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.Read(terminal.slave, buf)
+		if n <= 0 || errors.Is(err, syscall.EAGAIN) {
+			break
+		}
+		received.Write(buf[:n])
+	}
+	if received.Len() == 0 {
+		t.Fatal("nothing received")
+	}
+	if received.Len() == written {
+		t.Fatalf("all the %d bytes received, the terminal buffer never filled up", written)
+	}
+
+	epochPattern := regexp.MustCompile(`^\$EPOCH,(\d+)\r\n(?:` + regexp.QuoteMeta(sentence) + `){18}\$END,(\d+)\r\n`)
+	rest := received.String()
+	epochs := 0
+	for rest != "" {
+		match := epochPattern.FindStringSubmatch(rest)
+		if match == nil || match[1] != match[2] {
+			t.Fatalf("broken epoch after %d whole ones: %.80q", epochs, rest)
+		}
+		rest = rest[len(match[0]):]
+		epochs++
+	}
+}
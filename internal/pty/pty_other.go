@@ -0,0 +1,26 @@
+//go:build !linux
+
+package pty
+
+import "errors"
+
+var (
+	errUnsupported = errors.New("pseudo-terminal output is supported on Linux only")
+	errDropped     = errors.New("the pseudo-terminal buffer is full, the data is dropped")
+)
+
+type Terminal struct {
+	Path string
+}
+
+func Open() (*Terminal, error) {
+	return nil, errUnsupported
+}
+
+func (t *Terminal) Write(_ []byte) (int, error) {
+	return 0, errUnsupported
+}
+
+func (t *Terminal) Close() error {
+	return nil
+}
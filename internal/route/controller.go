@@ -4,14 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
 	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
 )
 
 type State uint8
@@ -22,6 +24,8 @@ func (s State) String() string {
 		return "Paused"
 	case Running:
 		return "Running"
+	case Finished:
+		return "Finished"
 	default:
 		return "Unknown"
 	}
@@ -30,10 +34,13 @@ func (s State) String() string {
 const (
 	Paused State = iota
 	Running
+	// Finished route has ended with the EndFinish behavior, the controller doesn't send points anymore
+	Finished
 )
 
 type LatLon struct {
-	Lat, Lon float64
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
 }
 
 type Point struct {
@@ -42,6 +49,26 @@ type Point struct {
 	Speed     float64 `json:"speed"`
 	Elevation float64 `json:"elevation"`
 	Track     float64 `json:"track"`
+	Climb     float64 `json:"climb,omitempty"`
+	// Mode is set when the point is inside an outage or the recorded fix is degraded
+	Mode uint `json:"mode,omitempty"`
+	// Status is the recorded TPV status, Satellites the recorded satellites in view
+	Status     uint            `json:"status,omitempty"`
+	Satellites []sky.Satellite `json:"satellites,omitempty"`
+	// Epx, Epy and Epv are the error estimates in meters set by the noise layer
+	Epx float64 `json:"epx,omitempty"`
+	Epy float64 `json:"epy,omitempty"`
+	Epv float64 `json:"epv,omitempty"`
+
+	GeoidSeparation   float64 `json:"geoidSeparation,omitempty"`
+	MagneticVariation float64 `json:"magneticVariation,omitempty"`
+	// Timestamp is the recorded time of the imported point
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Time is the simulated time of the point and LeapSeconds the GPS-UTC offset at it, WallTime is the wall
+	// clock time it's sent at
+	Time        time.Time `json:"-"`
+	LeapSeconds int       `json:"-"`
+	WallTime    time.Time `json:"-"`
 }
 
 func (p Point) String() string {
@@ -54,35 +81,23 @@ type Route struct {
 	Points   []Point
 	State    State
 	MaxSpeed uint
+	// Receiver overrides the controller defaults for this route
+	Receiver *Receiver `json:",omitempty"`
+	Outages  []Outage  `json:",omitempty"`
+	// Noise overrides the controller noise for this route
+	Noise *Noise `json:",omitempty"`
+	// Dynamics the speed profile was calculated with
+	Dynamics *Dynamics `json:",omitempty"`
+	// Vehicle the route was created for
+	Vehicle string `json:",omitempty"`
+	// End is the behavior at the end of the route, overrides the controller one
+	End string `json:",omitempty"`
 }
 
 func (r *Route) String() string {
 	return fmt.Sprintf("Route with %d points from %f,%f to %f,%f is currently %s", len(r.Points), r.Points[0].Lat, r.Points[0].Lon, r.Points[len(r.Points)-1].Lat, r.Points[len(r.Points)-1].Lon, r.State)
 }
 
-type GeoJsonFile struct {
-	Geometry GeoJsonGeometry `json:"geometry"`
-}
-
-type GeoJsonGeometry struct {
-	Type        string      `json:"type"`
-	Coordinates [][]float64 `json:"coordinates"`
-}
-
-func (g GeoJsonFile) Points() []Point {
-	points := make([]Point, 0, len(g.Geometry.Coordinates))
-	for _, coord := range g.Geometry.Coordinates {
-		if len(coord) < 2 {
-			continue // Skip invalid coordinates
-		}
-		points = append(points, Point{
-			Lat: coord[1],
-			Lon: coord[0],
-		})
-	}
-	return points
-}
-
 type Controller struct {
 	route         *Route
 	listeners     []chan Point
@@ -93,7 +108,20 @@ type Controller struct {
 	isRunning     bool
 	stepDelay     time.Duration
 	log           logger.Logger
-	stopTheLoop   chan struct{}
+	timeline      timeline
+	elapsed       time.Duration
+	receiver      Receiver
+	outages       []Outage
+	noise         Noise
+	noiseGen      *noiseGenerator
+	vehicle       Vehicle
+	end           string
+	length        float64
+	finished      chan struct{}
+	changed       chan struct{}
+	stepping      bool
+	steps         int
+	clock         *clock.SimClock
 }
 
 func NewController(parentCtx context.Context, stepDelay time.Duration, log logger.Logger) *Controller {
@@ -102,10 +130,14 @@ func NewController(parentCtx context.Context, stepDelay time.Duration, log logge
 			Points: make([]Point, 0),
 			State:  Paused,
 		},
-		listeners:   make([]chan Point, 0),
-		stepDelay:   stepDelay,
-		log:         log,
-		stopTheLoop: make(chan struct{}),
+		listeners: make([]chan Point, 0),
+		stepDelay: stepDelay,
+		log:       log,
+		noiseGen:  newNoiseGenerator(Noise{}),
+		end:       EndLoop,
+		clock:     clock.New(clock.Config{}),
+		finished:  make(chan struct{}),
+		changed:   make(chan struct{}, 1),
 	}
 
 	c.ctx, c.cancelFunc = context.WithCancel(parentCtx)
@@ -113,136 +145,242 @@ func NewController(parentCtx context.Context, stepDelay time.Duration, log logge
 }
 
 func (c *Controller) Startup() {
+	if c.Virtual() {
+		go c.virtualLoop()
+		return
+	}
 	go c.loop()
 }
 
-func (c *Controller) CreateRoute(name string, maxSpeed uint, points []Point) Route {
+// SetReceiver sets the receiver properties for the routes which don't define their own
+func (c *Controller) SetReceiver(receiver Receiver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.receiver = receiver
+}
+
+// SetOutages sets the outages applied to every route in addition to the route own outages
+func (c *Controller) SetOutages(outages []Outage) error {
+	for _, outage := range outages {
+		if err := outage.Validate(); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outages = outages
+	return nil
+}
+
+// SetRouteOutages replaces the outages of the current route
+func (c *Controller) SetRouteOutages(outages []Outage) error {
+	for _, outage := range outages {
+		if err := outage.Validate(); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.route.Outages = outages
+	c.log.Infof("Route: set %d outages", len(outages))
+	return nil
+}
+
+// SetVehicle sets the vehicle for the routes created without one
+func (c *Controller) SetVehicle(vehicle Vehicle) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vehicle = vehicle
+}
+
+// SetNoise sets the noise for the routes which don't define their own
+func (c *Controller) SetNoise(noise Noise) error {
+	if err := noise.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.noise = noise
+	c.resetNoise()
+	return nil
+}
+
+// SetRouteNoise changes the noise of the current route at runtime
+func (c *Controller) SetRouteNoise(noise Noise) error {
+	if err := noise.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.route.Noise = &noise
+	c.resetNoise()
+	c.log.Infof("Route: noise set to %s with sigma %.1fm", noise.Model, noise.Sigma)
+	return nil
+}
+
+// resetNoise restarts the noise processes from the seed, so the same route always gets the same errors.
+// Must be called with the mu locked.
+func (c *Controller) resetNoise() {
+	noise := c.noise
+	if c.route.Noise != nil {
+		noise = *c.route.Noise
+	}
+	c.noiseGen = newNoiseGenerator(noise)
+}
+
+func (c *Controller) StepDelay() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stepDelay
+}
+
+// SetStepDelay changes the update rate, the route is sampled by time so it keeps its speed and position
+func (c *Controller) SetStepDelay(stepDelay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stepDelay == c.stepDelay {
+		return
+	}
+	c.stepDelay = stepDelay
+	c.log.Infof("Route: update rate set to %.2fHz", 1/stepDelay.Seconds())
+}
+
+// CreateRoute keeps the route geometry and sets the speed profile, the speed at every vertex: the maximum speed,
+// or without it the speed to drive the segment ending at the vertex in one step, as the routes always did.
+// The dynamics then lower the speed in the turns, at the start and at the end of the route.
+// The vehicle, or the controller one when it's empty, provides the maximum speed and the dynamics when they
+// aren't set, the noise and the altitude profile.
+func (c *Controller) CreateRoute(name, vehicleName string, maxSpeed uint, dynamics Dynamics, points []Point) Route {
+	return c.createRoute(Track{Name: name, Points: points}, vehicleName, maxSpeed, dynamics)
+}
+
+// createRoute creates the route from the track. The maximum speed of the track is used when it isn't set. The speeds
+// of the track with the recorded time are driven in that time, unless the maximum speed is set, and replace the
+// vehicle speed and dynamics. The recorded elevations
+// replace the elevation service and the altitude profile.
+func (c *Controller) createRoute(input Track, vehicleName string, maxSpeed uint, dynamics Dynamics) Route {
+	name, points := input.Name, input.Points
+	if maxSpeed == 0 {
+		maxSpeed = input.MaxSpeed
+	}
+	timed := maxSpeed == 0 && recordedTimes(points)
+	c.mu.Lock()
+	vehicle := c.vehicle
+	c.mu.Unlock()
+	if vehicleName != "" {
+		var err error
+		if vehicle, err = LookupVehicle(vehicleName); err != nil {
+			c.log.Error("Route: ", err)
+		}
+	}
+	if maxSpeed == 0 && !timed {
+		maxSpeed = vehicle.MaxSpeed
+	}
+	if dynamics.IsZero() && !timed {
+		dynamics = vehicle.Dynamics
+	}
+	// the route replays the recorded times unless its speed is changed
+	replay := timed && dynamics.IsZero()
+
 	route := Route{
 		Name:     name,
 		MaxSpeed: maxSpeed,
 		Points:   make([]Point, 0, len(points)),
+		Vehicle:  vehicle.Name,
 	}
-
-	maxPointsDistance := float64(0)
-	if maxSpeed > 0 {
-		maxSpeedMs := float64(maxSpeed) / 3.6
-		maxPointsDistance = maxSpeedMs * c.stepDelay.Seconds()
+	if vehicle.Noise.enabled() {
+		noise := vehicle.Noise
+		route.Noise = &noise
 	}
 
-	distances := make(map[LatLon]float64, len(points))
-
 	for i, point := range points {
 		var speed float64
 		var track float64
 
 		if i > 0 {
 			prevIndex := len(route.Points) - 1
-			// Skip the same point
-			if route.Points[prevIndex].Lat == point.Lat && route.Points[prevIndex].Lon == point.Lon {
+			// Skip the same point, unless it's recorded at another time
+			if route.Points[prevIndex].Lat == point.Lat && route.Points[prevIndex].Lon == point.Lon && !replay {
 				continue
 			}
-			speed = calculateSpeedMetersPerSecond(route.Points[prevIndex].Lat, route.Points[prevIndex].Lon, point.Lat, point.Lon, c.stepDelay)
+			switch {
+			case replay && input.Speeds:
+				speed = point.Speed
+			case timed:
+				speed = trackSpeed(route.Points[prevIndex], point)
+			case maxSpeed > 0:
+				speed = float64(maxSpeed) / 3.6
+			default:
+				speed = calculateSpeedMetersPerSecond(route.Points[prevIndex].Lat, route.Points[prevIndex].Lon, point.Lat, point.Lon, c.stepDelay)
+			}
 			track = calculateInitialBearing(route.Points[prevIndex].Lat, route.Points[prevIndex].Lon, point.Lat, point.Lon)
-			pointsDistance := calculateHaversineDistance(route.Points[prevIndex].Lat, route.Points[prevIndex].Lon, point.Lat, point.Lon)
-			distances[LatLon{Lat: point.Lat, Lon: point.Lon}] = pointsDistance
-			route.Distance += pointsDistance
+			if replay && input.Speeds {
+				track = point.Track
+			}
+			route.Distance += calculateHaversineDistance(route.Points[prevIndex].Lat, route.Points[prevIndex].Lon, point.Lat, point.Lon)
 		}
 
-		route.Points = append(route.Points, Point{Lat: point.Lat, Lon: point.Lon, Speed: speed, Track: track})
+		routePoint := Point{Lat: point.Lat, Lon: point.Lon, Speed: speed, Track: track, Elevation: point.Elevation}
+		if replay {
+			routePoint.Timestamp = point.Timestamp
+			routePoint.Mode, routePoint.Status, routePoint.Satellites = point.Mode, point.Status, point.Satellites
+		}
+		route.Points = append(route.Points, routePoint)
 	}
 
-	if err := c.updateRouteElevations(&route); err != nil {
-		c.log.Error("Route: error updating route elevations: ", err)
+	if input.Speeds && replay && len(route.Points) > 0 {
+		route.Points[0].Speed = points[0].Speed
+		route.Points[0].Track = points[0].Track
+	} else if len(route.Points) > 1 {
+		route.Points[0].Speed = route.Points[1].Speed
+		route.Points[0].Track = route.Points[1].Track
 	}
 
-	// Tune route points not to reach the maximum speed
-	if len(route.Points) > 2 && maxPointsDistance > 0 {
-		newPoints := make([]Point, 0, len(route.Points))
-
-		for i, point := range route.Points {
-			if i == 0 {
-				newPoints = append(newPoints, point)
-				continue
-			}
-			prevIndex := len(newPoints) - 1
-			pointsDistance := distances[LatLon{Lat: point.Lat, Lon: point.Lon}]
-
-			if pointsDistance <= maxPointsDistance {
-				newPoints = append(newPoints, point)
-				continue
-			}
-			// Calculate number of segments needed based on exact distance
-			numSegments := int(math.Ceil(pointsDistance/maxPointsDistance)) - 1
-
-			// Origin point coordinates
-			lat1 := newPoints[prevIndex].Lat
-			lon1 := newPoints[prevIndex].Lon
-
-			// Calculate bearing once (direction from start to end)
-			bearing := calculateInitialBearing(lat1, lon1, point.Lat, point.Lon)
-			bearingRad := degreesToRadians(bearing)
-
-			for j := 1; j <= numSegments; j++ {
-				// Calculate exact distance from origin for this point
-				exactDistance := float64(j) * maxPointsDistance
-
-				// Calculate intermediate point at exactly this distance from origin
-				lat1Rad := degreesToRadians(lat1)
-				lon1Rad := degreesToRadians(lon1)
-
-				// Angular distance in radians
-				angularDistance := exactDistance / earthRadiusMeters
-
-				// Calculate intermediate point using exact distance
-				segmentLatRad := math.Asin(math.Sin(lat1Rad)*math.Cos(angularDistance) +
-					math.Cos(lat1Rad)*math.Sin(angularDistance)*math.Cos(bearingRad))
-				segmentLonRad := lon1Rad + math.Atan2(math.Sin(bearingRad)*math.Sin(angularDistance)*math.Cos(lat1Rad),
-					math.Cos(angularDistance)-math.Sin(lat1Rad)*math.Sin(segmentLatRad))
-
-				segmentLat := radiansToDegrees(segmentLatRad)
-				segmentLon := radiansToDegrees(segmentLonRad)
-
-				prevIndex = len(newPoints) - 1
-				segmentSpeed := calculateSpeedMetersPerSecond(newPoints[prevIndex].Lat, newPoints[prevIndex].Lon, segmentLat, segmentLon, c.stepDelay)
-				newPoints = append(newPoints, Point{Lat: segmentLat, Lon: segmentLon, Speed: segmentSpeed, Track: bearing, Elevation: newPoints[prevIndex].Elevation})
-			}
+	// boats stay at the sea level
+	if vehicle.Altitude != AltitudeSurface && !input.Elevations {
+		if err := c.updateRouteElevations(&route); err != nil {
+			c.log.Error("Route: error updating route elevations: ", err)
 		}
+	}
 
-		if len(newPoints) > len(route.Points) {
-			route.Points = newPoints
-		}
+	if !dynamics.IsZero() {
+		route.Points = dynamics.apply(route.Points)
+		route.Dynamics = &dynamics
+	}
+	if vehicle.Altitude == AltitudeFlight && !input.Elevations {
+		vehicle.flightElevations(route.Points, float64(maxSpeed)/3.6)
 	}
 
 	return route
 }
 
-func (c *Controller) UpdateRoute(name string, maxSpeed uint, points []Point) {
-	newRoute := c.CreateRoute(name, maxSpeed, points)
-
-	c.stopTheLoop <- struct{}{}
+func (c *Controller) UpdateRoute(name, vehicle string, maxSpeed uint, dynamics Dynamics, points []Point) {
+	newRoute := c.CreateRoute(name, vehicle, maxSpeed, dynamics, points)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.route = &newRoute
+	c.elapsed = 0
+	c.resetTimeline()
+	c.resetNoise()
 	if len(c.route.Points) > 0 {
 		c.route.State = Running
 	} else {
 		c.route.State = Paused
 	}
 
+	c.notifyChanged()
 	c.log.Infof("Route: updated route with %d points", len(c.route.Points))
 }
 
 func (c *Controller) ToggleState() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.route.State == Running {
-		c.route.State = Paused
-		c.log.Infof("Route: paused")
+	if c.route.State == Running && !c.stepping {
+		c.pause()
 	} else {
-		c.route.State = Running
-		c.log.Infof("Route: running")
+		c.run()
 	}
 }
 
@@ -250,9 +388,21 @@ func (c *Controller) Subscribe() (chan Point, func()) {
 	c.listenersLock.Lock()
 	defer c.listenersLock.Unlock()
 	listener := make(chan Point)
+	select {
+	case <-c.finished:
+		close(listener)
+		return listener, func() {}
+	default:
+	}
 	c.listeners = append(c.listeners, listener)
+	c.notifyChanged()
 
 	return listener, func() {
+		// broadcast may be blocked on this listener while holding the lock, so keep draining it until it's closed
+		go func() {
+			for range listener {
+			}
+		}()
 		c.listenersLock.Lock()
 		defer c.listenersLock.Unlock()
 		for i, l := range c.listeners {
@@ -293,22 +443,37 @@ func (c *Controller) GetRoute() Route {
 		Points:   make([]Point, len(c.route.Points)),
 		State:    c.route.State,
 		MaxSpeed: c.route.MaxSpeed,
+		Receiver: c.route.Receiver,
+		Outages:  make([]Outage, len(c.route.Outages)),
+		Noise:    c.route.Noise,
+		Dynamics: c.route.Dynamics,
+		Vehicle:  c.route.Vehicle,
+		End:      c.route.End,
 	}
 	copy(clone.Points, c.route.Points)
+	copy(clone.Outages, c.route.Outages)
 
 	return clone
 }
 
 func (c *Controller) SetRoute(route Route) {
-	c.stopTheLoop <- struct{}{}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.elapsed = 0
 	c.route.Name = route.Name
 	c.route.Distance = route.Distance
 	c.route.MaxSpeed = route.MaxSpeed
+	c.route.Receiver = route.Receiver
+	c.route.Outages = route.Outages
+	c.route.Noise = route.Noise
+	c.route.Dynamics = route.Dynamics
+	c.route.Vehicle = route.Vehicle
+	c.route.End = route.End
 	c.route.Points = make([]Point, len(route.Points))
 	copy(c.route.Points, route.Points)
+	c.resetTimeline()
+	c.resetNoise()
 
 	if len(c.route.Points) > 0 {
 		c.route.State = Running
@@ -316,13 +481,27 @@ func (c *Controller) SetRoute(route Route) {
 		c.route.State = Paused
 	}
 
+	c.notifyChanged()
 	c.log.Infof("Route: loaded route with %d points", len(c.route.Points))
 }
 
+// LoadRouteFromFile loads the route file, or creates the route from a track file recognized by its extension,
+// so the recorded tracks replay with their own timing
 func (c *Controller) LoadRouteFromFile(filePath string) error {
 	if filePath == "" {
 		return nil
 	}
+	if slices.Contains(trackExtensions, strings.ToLower(filepath.Ext(filePath))) {
+		track, err := readTrack(filePath, "")
+		if err != nil {
+			return err
+		}
+		if track.Name == "" {
+			track.Name = filepath.Base(filePath)
+		}
+		c.SetRoute(c.createRoute(track, "", 0, Dynamics{}))
+		return nil
+	}
 
 	file, err := os.OpenFile(filePath, os.O_RDONLY, 0644)
 	if err != nil {
@@ -338,24 +517,25 @@ func (c *Controller) LoadRouteFromFile(filePath string) error {
 	return nil
 }
 
-func (c *Controller) Import(name, inputFile, outputFile string, speed uint) error {
-	fmt.Printf("name: %s, input: %s, output: %s, speed: %d\n", name, inputFile, outputFile, speed)
-	input, err := os.OpenFile(inputFile, os.O_RDONLY, 0644)
+// Import converts the GeoJSON, GPX, KML, KMZ or NMEA file into a route file, receiver is stored in the route when it's not nil.
+// The feature selects a feature of the GeoJSON file by its index or name.
+func (c *Controller) Import(name, inputFile, feature, outputFile, vehicle string, speed uint, dynamics Dynamics, receiver *Receiver) error {
+	c.log.Debugf("Route: importing %s to %s, name: %s, speed: %d", inputFile, outputFile, name, speed)
+	track, err := readTrack(inputFile, feature)
 	if err != nil {
-		return fmt.Errorf("failed to open input file %s: %w", inputFile, err)
+		return err
 	}
-	defer input.Close()
 
-	inputData := GeoJsonFile{}
-	if err = json.NewDecoder(input).Decode(&inputData); err != nil {
-		return fmt.Errorf("failed to decode input file %s: %w", inputFile, err)
+	if name != "" {
+		track.Name = name
 	}
-
-	if name == "" {
-		name = fmt.Sprintf("Route %s", time.Now().Format(time.DateTime))
+	if track.Name == "" {
+		track.Name = fmt.Sprintf("Route %s", time.Now().Format(time.DateTime))
 	}
+	name = track.Name
 
-	route := c.CreateRoute(name, speed, inputData.Points())
+	route := c.createRoute(track, vehicle, speed, dynamics)
+	route.Receiver = receiver
 
 	if outputFile == "" {
 		dir := filepath.Dir(inputFile)
@@ -392,6 +572,134 @@ func (c *Controller) Import(name, inputFile, outputFile string, speed uint) erro
 	return nil
 }
 
+// nextPoint samples the route at the current simulated time and advances it by one step, a paused route stays
+// at the same place. The receiver, outages and noise are applied here, so all the outputs see the same point.
+func (c *Controller) nextPoint() (Point, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.route.Points) == 0 {
+		return Point{}, false
+	}
+	if c.route.State == Finished {
+		return Point{}, false
+	}
+
+	end := c.endBehavior()
+	duration := time.Duration(c.timeline.duration() * float64(time.Second))
+	if c.elapsed > duration {
+		switch end {
+		case EndStop, EndFinish:
+			// the last point is always reported
+			c.elapsed = duration
+		default:
+			c.log.Debugf("Route: starting the loop for %d points", len(c.route.Points))
+			c.elapsed = 0
+		}
+	}
+
+	elapsed := c.elapsed
+	point, distance := c.timeline.sample(elapsed.Seconds())
+	distance = routeDistance(distance, c.length, end)
+	atEnd := elapsed == duration && (end == EndStop || end == EndFinish)
+	switch {
+	case c.route.State == Paused || atEnd:
+		point.Speed = 0
+		point.Climb = 0
+	case c.route.State == Running:
+		c.elapsed += c.simulatedStep()
+	}
+	if atEnd && end == EndFinish && c.route.State == Running {
+		c.route.State = Finished
+		c.log.Infof("Route: finished")
+	}
+
+	receiver := c.receiver
+	if c.route.Receiver != nil {
+		receiver = *c.route.Receiver
+	}
+	point = receiver.apply(point)
+	if mode := c.outageMode(point, distance, elapsed); mode != 0 && (point.Mode == 0 || mode < point.Mode) {
+		point.Mode = mode
+		if point.Satellites != nil {
+			point.Satellites = sky.Degrade(point.Satellites, mode)
+		}
+	}
+	point = c.noiseGen.apply(point, c.simulatedStep())
+	point.Time = c.clock.Now()
+	point.LeapSeconds = c.clock.LeapSeconds(point.Time)
+	point.WallTime = c.clock.Wall()
+
+	return point, true
+}
+
+// outageMode checks the point at the distance along the route and the time since the route start against
+// the controller and the route outages. Must be called with the mu locked.
+func (c *Controller) outageMode(point Point, distance float64, elapsed time.Duration) uint {
+	if len(c.outages) == 0 && len(c.route.Outages) == 0 {
+		return 0
+	}
+	return outageMode(slices.Concat(c.outages, c.route.Outages), point, distance, elapsed.Seconds())
+}
+
+// SetEnd sets the end behavior for the routes which don't define their own
+func (c *Controller) SetEnd(end string) error {
+	if err := ValidateEnd(end); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.end = end
+	c.resetTimeline()
+	return nil
+}
+
+// SetRouteEnd changes the end behavior of the current route at runtime
+func (c *Controller) SetRouteEnd(end string) error {
+	if err := ValidateEnd(end); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.route.End = end
+	c.resetTimeline()
+	c.log.Infof("Route: end behavior set to %s", c.endBehavior())
+	return nil
+}
+
+// Finished is closed when the route has ended with the EndFinish behavior
+func (c *Controller) Finished() <-chan struct{} {
+	return c.finished
+}
+
+// endBehavior must be called with the mu locked
+func (c *Controller) endBehavior() string {
+	if c.route.End != "" {
+		return c.route.End
+	}
+	return c.end
+}
+
+// resetTimeline builds the timeline of the path driven until the route starts again. Must be called with the mu locked.
+func (c *Controller) resetTimeline() {
+	c.timeline = newTimeline(endPath(c.route.Points, c.endBehavior()))
+	c.length = 0
+	if len(c.route.Points) > 0 {
+		c.length = c.timeline.distances[len(c.route.Points)-1]
+	}
+}
+
+// finish closes the listeners, so the clients know there are no more points
+func (c *Controller) finish() {
+	c.listenersLock.Lock()
+	defer c.listenersLock.Unlock()
+	for _, listener := range c.listeners {
+		close(listener)
+	}
+	c.listeners = nil
+	close(c.finished)
+}
+
 func (c *Controller) broadcast(point Point) {
 	c.listenersLock.Lock()
 	defer c.listenersLock.Unlock()
@@ -401,54 +709,45 @@ func (c *Controller) broadcast(point Point) {
 }
 
 func (c *Controller) loop() {
-	stepTimer := time.NewTimer(c.stepDelay)
-loop:
+	stepTimer := time.NewTimer(c.StepDelay())
+	defer stepTimer.Stop()
 	for {
-		c.mu.Lock()
-		pointsLen := len(c.route.Points)
-		c.mu.Unlock()
-
 		select {
-		case <-c.stopTheLoop:
+		case <-c.ctx.Done():
+			c.log.Infof("Route: the controller loop stopped")
+			return
+		case <-stepTimer.C:
+			if c.Stepping() {
+				stepTimer.Reset(c.StepDelay())
+				continue
+			}
+		case <-c.changed:
+			// a point requested in the stepping mode is sent right away, and the held clock moves by the step
+			if !c.Stepping() || !c.takeStep() {
+				continue
+			}
+			if !c.send() {
+				return
+			}
+			c.clock.Advance(c.StepDelay())
 			continue
-		default:
 		}
 
-		if pointsLen > 0 {
-			c.log.Debugf("Route: starting the loop for %d points", pointsLen)
-		} else {
-			// No points in the route, wait for new route
-			<-stepTimer.C
-			stepTimer.Reset(c.stepDelay)
-			continue
+		if !c.send() {
+			return
 		}
+		stepTimer.Reset(c.StepDelay())
+	}
+}
 
-		for i := 0; i < pointsLen; i++ {
-			select {
-			case <-c.ctx.Done():
-				c.log.Infof("Route: the controller loop stopped")
-				return
-			case <-c.stopTheLoop:
-				c.log.Infof("Route: route need to be updated - stop the loop")
-				continue loop
-			case <-stepTimer.C:
-			}
-
-			var point Point
-
-			switch c.GetState() {
-			case Paused:
-				if i > 0 {
-					i--
-				}
-				point = c.route.Points[i]
-				point.Speed = 0
-			case Running:
-				point = c.route.Points[i]
-			}
-
-			c.broadcast(point)
-			stepTimer.Reset(c.stepDelay)
-		}
+// send broadcasts the next point, it returns false when the route has finished
+func (c *Controller) send() bool {
+	if point, ok := c.nextPoint(); ok {
+		c.broadcast(point)
+	}
+	if c.GetState() == Finished {
+		c.finish()
+		return false
 	}
+	return true
 }
@@ -0,0 +1,32 @@
+package route
+
+import (
+	"context"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
+	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+)
+
+// newTestController returns the controller running the route on the virtual clock, with a step of a second. The
+// points are sampled with nextPoint, without the controller loop.
+func newTestController(points []Point) *Controller {
+	controller := NewController(context.Background(), time.Second, logger.NewStdoutLogger(logger.LevelFatal))
+	controller.clock = clock.NewVirtual(clock.Config{})
+	controller.SetRoute(Route{Points: points})
+	return controller
+}
+
+// drive samples the next count points, advancing the virtual clock by the step the same way the virtual loop does
+func drive(controller *Controller, count int) []Point {
+	points := make([]Point, 0, count)
+	for range count {
+		point, ok := controller.nextPoint()
+		if !ok {
+			break
+		}
+		points = append(points, point)
+		controller.clock.Advance(controller.StepDelay())
+	}
+	return points
+}
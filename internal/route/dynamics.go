@@ -0,0 +1,117 @@
+package route
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// maxSegmentLength is the longest segment of a route with the dynamics, longer segments are split, so the speed
+// could change along them
+const maxSegmentLength = 100.0
+
+// Dynamics limits how fast the vehicle changes its speed, zero means there is no limit
+type Dynamics struct {
+	// MaxAcceleration in m/s², the route starts from standstill when it's set
+	MaxAcceleration float64 `json:"maxAcceleration,omitempty"`
+	// MaxDeceleration in m/s², the route ends with a standstill when it's set
+	MaxDeceleration float64 `json:"maxDeceleration,omitempty"`
+	// MaxLateralAcceleration in m/s², limits the speed in the turns
+	MaxLateralAcceleration float64 `json:"maxLateralAcceleration,omitempty"`
+}
+
+func (d Dynamics) Validate() error {
+	if d.MaxAcceleration < 0 || d.MaxDeceleration < 0 || d.MaxLateralAcceleration < 0 {
+		return errors.New("dynamics limits must not be negative")
+	}
+	return nil
+}
+
+func (d Dynamics) IsZero() bool {
+	return d == Dynamics{}
+}
+
+// apply splits the long segments and lowers the speed at every point: to pass the turn with the lateral
+// acceleration limit, then to reach it from the previous point and to stop in time for the next one.
+func (d Dynamics) apply(points []Point) []Point {
+	if d.IsZero() || len(points) < 2 {
+		return points
+	}
+	points = splitSegments(points, maxSegmentLength)
+
+	last := len(points) - 1
+	distances := make([]float64, len(points))
+	for i := 1; i <= last; i++ {
+		distances[i] = calculateHaversineDistance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+	}
+
+	if d.MaxLateralAcceleration > 0 {
+		for i := 1; i < last; i++ {
+			radius := turnRadius(points[i-1], points[i], points[i+1])
+			points[i].Speed = min(points[i].Speed, math.Sqrt(d.MaxLateralAcceleration*radius))
+		}
+	}
+	if d.MaxAcceleration > 0 {
+		points[0].Speed = 0
+		for i := 1; i <= last; i++ {
+			points[i].Speed = min(points[i].Speed, math.Sqrt(points[i-1].Speed*points[i-1].Speed+2*d.MaxAcceleration*distances[i]))
+		}
+	}
+	if d.MaxDeceleration > 0 {
+		points[last].Speed = 0
+		for i := last - 1; i >= 0; i-- {
+			points[i].Speed = min(points[i].Speed, math.Sqrt(points[i+1].Speed*points[i+1].Speed+2*d.MaxDeceleration*distances[i+1]))
+		}
+	}
+	return points
+}
+
+// splitSegments adds the points along the segments longer than maxLength, the speed is the same along the segment
+// and the elevation changes linearly
+func splitSegments(points []Point, maxLength float64) []Point {
+	result := make([]Point, 0, len(points))
+	result = append(result, points[0])
+	for i := 1; i < len(points); i++ {
+		prev, next := points[i-1], points[i]
+		distance := calculateHaversineDistance(prev.Lat, prev.Lon, next.Lat, next.Lon)
+		parts := int(math.Ceil(distance / maxLength))
+		if parts > 1 {
+			bearing := calculateInitialBearing(prev.Lat, prev.Lon, next.Lat, next.Lon)
+			for j := 1; j < parts; j++ {
+				fraction := float64(j) / float64(parts)
+				lat, lon := calculateDestination(prev.Lat, prev.Lon, bearing, distance*fraction)
+				point := Point{
+					Lat:       lat,
+					Lon:       lon,
+					Speed:     next.Speed,
+					Track:     bearing,
+					Elevation: prev.Elevation + (next.Elevation-prev.Elevation)*fraction,
+				}
+				if prev.Timestamp != nil && next.Timestamp != nil {
+					timestamp := prev.Timestamp.Add(time.Duration(float64(next.Timestamp.Sub(*prev.Timestamp)) * fraction))
+					point.Timestamp = &timestamp
+				}
+				result = append(result, point)
+			}
+		}
+		result = append(result, next)
+	}
+	return result
+}
+
+// turnRadius is the radius of the circle through three consecutive points, +Inf for a straight line
+func turnRadius(a, b, c Point) float64 {
+	ac := calculateHaversineDistance(a.Lat, a.Lon, c.Lat, c.Lon)
+	// the angle between the segments is the change of the direction
+	turn := degreesToRadians(calculateInitialBearing(b.Lat, b.Lon, c.Lat, c.Lon) - calculateInitialBearing(a.Lat, a.Lon, b.Lat, b.Lon))
+	sin := math.Abs(math.Sin(turn))
+	if sin < 1e-9 {
+		if math.Cos(turn) < 0 {
+			// U-turn
+			return 0
+		}
+		return math.Inf(1)
+	}
+	// circumradius from the chord ac and the inscribed angle at b, which is 180° minus the turn
+	return ac / (2 * sin)
+}
@@ -0,0 +1,98 @@
+package route
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDynamicsValidate(t *testing.T) {
+	if err := (Dynamics{MaxAcceleration: 2, MaxDeceleration: 3, MaxLateralAcceleration: 4}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (Dynamics{MaxLateralAcceleration: -1}).Validate(); err == nil {
+		t.Error("got no error for the negative limit")
+	}
+}
+
+func TestSplitSegments(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(100 * time.Second)
+	points := line(45, []float64{0, 50, 1050}, []float64{10, 10, 20})
+	points[1].Timestamp, points[2].Timestamp = &start, &end
+	points[1].Elevation, points[2].Elevation = 100, 200
+
+	split := splitSegments(points, maxSegmentLength)
+	if len(split) != 12 {
+		t.Fatalf("got %d points, want 12", len(split))
+	}
+	for i := 1; i < len(split); i++ {
+		if distance := calculateHaversineDistance(split[i-1].Lat, split[i-1].Lon, split[i].Lat, split[i].Lon); distance > maxSegmentLength+1e-6 {
+			t.Errorf("point %d: got the segment of %f m", i, distance)
+		}
+	}
+	middle := split[6]
+	if middle.Speed != 20 || !near(middle.Elevation, 150, 1e-9) || !near(middle.Track, 45, 0.01) || middle.Timestamp == nil || !middle.Timestamp.Equal(start.Add(50*time.Second)) {
+		t.Errorf("got the middle point %+v", middle)
+	}
+}
+
+func TestTurnRadius(t *testing.T) {
+	// along the meridian the bearing does not drift
+	points := line(0, []float64{0, 100, 200}, []float64{0, 0, 0})
+	a, b, further := points[0], points[1], points[2]
+	lat, lon := calculateDestination(b.Lat, b.Lon, 90, 100)
+	turned := Point{Lat: lat, Lon: lon}
+
+	if radius := turnRadius(a, b, further); !math.IsInf(radius, 1) {
+		t.Errorf("got radius %f of the straight line", radius)
+	}
+	if radius := turnRadius(a, b, a); radius != 0 {
+		t.Errorf("got radius %f of the U-turn", radius)
+	}
+	if radius := turnRadius(a, b, turned); !near(radius, 100/math.Sqrt2, 0.5) {
+		t.Errorf("got radius %f of the right angle turn, want %f", radius, 100/math.Sqrt2)
+	}
+}
+
+func TestDynamicsApply(t *testing.T) {
+	// straight 1 km, then a right angle turn
+	points := line(90, []float64{0, 1000}, []float64{30, 30})
+	lat, lon := calculateDestination(points[1].Lat, points[1].Lon, 180, 1000)
+	points = append(points, Point{Lat: lat, Lon: lon, Speed: 30})
+	dynamics := Dynamics{MaxAcceleration: 2, MaxDeceleration: 3, MaxLateralAcceleration: 4}
+
+	applied := dynamics.apply(points)
+	last := len(applied) - 1
+	if applied[0].Speed != 0 || applied[last].Speed != 0 {
+		t.Errorf("got the speeds %f at the start and %f at the end, want standstills", applied[0].Speed, applied[last].Speed)
+	}
+	for i := 1; i <= last; i++ {
+		prev, point := applied[i-1], applied[i]
+		distance := calculateHaversineDistance(prev.Lat, prev.Lon, point.Lat, point.Lon)
+		change := point.Speed*point.Speed - prev.Speed*prev.Speed
+		if change > 2*dynamics.MaxAcceleration*distance+1e-6 || -change > 2*dynamics.MaxDeceleration*distance+1e-6 {
+			t.Errorf("point %d: got the speed change from %f to %f over %f m", i, prev.Speed, point.Speed, distance)
+		}
+		if point.Speed > 30 {
+			t.Errorf("point %d: got the speed %f over the limit", i, point.Speed)
+		}
+	}
+
+	// the turn is at the point 1 km from the start, which splitting keeps
+	turn := applied[10]
+	if !near(turn.Lat, points[1].Lat, 1e-9) || !near(turn.Lon, points[1].Lon, 1e-9) {
+		t.Fatalf("got the point %v, want the turn at %v", turn, points[1])
+	}
+	if radius := turnRadius(applied[9], turn, applied[11]); !near(turn.Speed, math.Sqrt(dynamics.MaxLateralAcceleration*radius), 1e-6) {
+		t.Errorf("got the speed %f in the turn of radius %f, want %f", turn.Speed, radius, math.Sqrt(dynamics.MaxLateralAcceleration*radius))
+	}
+	// between the limits the route keeps its speed
+	if cruise := applied[5].Speed; cruise != 30 {
+		t.Errorf("got the cruise speed %f, want 30", cruise)
+	}
+
+	if unchanged := (Dynamics{}).apply(points); len(unchanged) != len(points) || unchanged[0].Speed != 30 {
+		t.Error("got the route changed without the dynamics")
+	}
+}
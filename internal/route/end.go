@@ -0,0 +1,58 @@
+package route
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// EndStop keeps reporting the last point with zero speed
+	EndStop = "stop"
+	// EndLoop starts the route again from the first point
+	EndLoop = "loop"
+	// EndLoopReturn drives straight back to the first point and starts the route again
+	EndLoopReturn = "loop-return"
+	// EndReverse drives the route backwards to the first point and starts it again
+	EndReverse = "reverse"
+	// EndFinish stops the simulation and closes the client streams
+	EndFinish = "end"
+)
+
+var EndBehaviors = []string{EndStop, EndLoop, EndLoopReturn, EndReverse, EndFinish}
+
+func ValidateEnd(end string) error {
+	if end != "" && !slices.Contains(EndBehaviors, end) {
+		return fmt.Errorf("unsupported end behavior %q, expected one of %v", end, EndBehaviors)
+	}
+	return nil
+}
+
+// endPath returns the points driven before the route starts again
+func endPath(points []Point, end string) []Point {
+	if len(points) < 2 {
+		return points
+	}
+	switch end {
+	case EndLoopReturn:
+		// the way back has no speed profile, so it's driven with the top speed of the route
+		start := points[0]
+		for _, point := range points {
+			start.Speed = max(start.Speed, point.Speed)
+		}
+		return append(slices.Clone(points), start)
+	case EndReverse:
+		back := slices.Clone(points[:len(points)-1])
+		slices.Reverse(back)
+		return slices.Concat(points, back)
+	}
+	return points
+}
+
+// routeDistance converts the distance driven along the path into the distance along the route, the path of
+// the reversed route goes back along it
+func routeDistance(distance, length float64, end string) float64 {
+	if end == EndReverse && distance > length {
+		return 2*length - distance
+	}
+	return distance
+}
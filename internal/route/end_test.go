@@ -0,0 +1,123 @@
+package route
+
+import (
+	"testing"
+)
+
+func TestValidateEnd(t *testing.T) {
+	for _, end := range append([]string{""}, EndBehaviors...) {
+		if err := ValidateEnd(end); err != nil {
+			t.Errorf("%q: %v", end, err)
+		}
+	}
+	if err := ValidateEnd("bounce"); err == nil {
+		t.Error("got no error for the unsupported end behavior")
+	}
+}
+
+func TestEndPath(t *testing.T) {
+	points := line(90, []float64{0, 100, 300}, []float64{5, 10, 7})
+
+	if path := endPath(points, EndLoop); len(path) != 3 {
+		t.Errorf("got %d points of the loop, want the route", len(path))
+	}
+	path := endPath(points, EndLoopReturn)
+	if last := path[len(path)-1]; len(path) != 4 || last.Lat != points[0].Lat || last.Lon != points[0].Lon || last.Speed != 10 {
+		t.Errorf("got %d points ending at %+v, want the start at the top speed", len(path), last)
+	}
+	path = endPath(points, EndReverse)
+	if len(path) != 5 || path[3].Lat != points[1].Lat || path[3].Lon != points[1].Lon || path[4].Lon != points[0].Lon {
+		t.Errorf("got the reversed path %v", path)
+	}
+	if points[2].Speed != 7 {
+		t.Error("got the route points changed")
+	}
+
+	if distance := routeDistance(250, 300, EndReverse); distance != 250 {
+		t.Errorf("got distance %f on the way there, want 250", distance)
+	}
+	if distance := routeDistance(450, 300, EndReverse); distance != 150 {
+		t.Errorf("got distance %f on the way back, want 150", distance)
+	}
+	if distance := routeDistance(350, 300, EndLoopReturn); distance != 350 {
+		t.Errorf("got distance %f on the return, want 350", distance)
+	}
+}
+
+// TestControllerEnd drives 105 m at 10 m/s, which ends the route in the middle of the 11th step
+func TestControllerEnd(t *testing.T) {
+	route := line(90, []float64{0, 105}, []float64{10, 10})
+	start := route[0]
+	tests := []struct {
+		end    string
+		points int
+		// the distance from the start at the 15th step
+		distance float64
+		speed    float64
+		state    State
+	}{
+		{EndStop, 15, 105, 0, Running},
+		{EndFinish, 12, 105, 0, Finished},
+		{EndLoop, 15, 30, 10, Running},
+		{EndReverse, 15, 70, 10, Running},
+		{EndLoopReturn, 15, 70, 10, Running},
+	}
+	for _, test := range tests {
+		t.Run(test.end, func(t *testing.T) {
+			controller := newTestController(route)
+			if err := controller.SetEnd(test.end); err != nil {
+				t.Fatal(err)
+			}
+			points := drive(controller, 15)
+			if len(points) != test.points || controller.GetState() != test.state {
+				t.Fatalf("got %d points in the %s state, want %d in %s", len(points), controller.GetState(), test.points, test.state)
+			}
+			if at := points[10]; !near(calculateHaversineDistance(start.Lat, start.Lon, at.Lat, at.Lon), 100, 0.01) {
+				t.Errorf("got the point %f,%f after 10 steps, want 100 m from the start", at.Lat, at.Lon)
+			}
+			last := points[len(points)-1]
+			if distance := calculateHaversineDistance(start.Lat, start.Lon, last.Lat, last.Lon); !near(distance, test.distance, 0.01) || last.Speed != test.speed {
+				t.Errorf("got the last point %f m from the start at %f m/s, want %f m at %f m/s", distance, last.Speed, test.distance, test.speed)
+			}
+		})
+	}
+}
+
+func TestControllerRouteEnd(t *testing.T) {
+	controller := newTestController(line(90, []float64{0, 100}, []float64{10, 10}))
+	if err := controller.SetRouteEnd(EndFinish); err != nil {
+		t.Fatal(err)
+	}
+	// the route end behavior overrides the controller one
+	if err := controller.SetEnd(EndLoop); err != nil {
+		t.Fatal(err)
+	}
+	listener, _ := controller.Subscribe()
+	go func() {
+		for range listener {
+		}
+	}()
+	for range 20 {
+		if !controller.send() {
+			break
+		}
+		controller.clock.Advance(controller.StepDelay())
+	}
+	select {
+	case <-controller.Finished():
+	default:
+		t.Fatal("got the route not finished")
+	}
+	if _, ok := <-listener; ok {
+		t.Error("got the listener open after the finish")
+	}
+	if listener, _ = controller.Subscribe(); listener == nil {
+		t.Fatal("got no listener")
+	}
+	if _, ok := <-listener; ok {
+		t.Error("got the listener subscribed after the finish open")
+	}
+	if err := controller.SetRouteEnd("bounce"); err == nil {
+		t.Error("got no error for the unsupported end behavior")
+	}
+}
@@ -0,0 +1,4 @@
+package route
+
+// DecodeNmea exposes the NMEA log decoder to the tests of the encoder, which can't be imported from package route
+var DecodeNmea = decodeNmea
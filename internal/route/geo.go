@@ -51,3 +51,18 @@ func calculateSpeedMetersPerSecond(lat1, lon1, lat2, lon2 float64, duration time
 	}
 	return distance / seconds
 }
+
+// calculateDestination returns the point at the distance in meters from the origin along the great circle with the initial bearing
+func calculateDestination(lat, lon, bearing, distance float64) (float64, float64) {
+	latRad := degreesToRadians(lat)
+	lonRad := degreesToRadians(lon)
+	bearingRad := degreesToRadians(bearing)
+	angularDistance := distance / earthRadiusMeters
+
+	destinationLatRad := math.Asin(math.Sin(latRad)*math.Cos(angularDistance) +
+		math.Cos(latRad)*math.Sin(angularDistance)*math.Cos(bearingRad))
+	destinationLonRad := lonRad + math.Atan2(math.Sin(bearingRad)*math.Sin(angularDistance)*math.Cos(latRad),
+		math.Cos(angularDistance)-math.Sin(latRad)*math.Sin(destinationLatRad))
+
+	return radiansToDegrees(destinationLatRad), radiansToDegrees(destinationLonRad)
+}
@@ -0,0 +1,188 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GeoJsonFile is a FeatureCollection, a single Feature or a bare geometry
+type GeoJsonFile struct {
+	Type     string           `json:"type"`
+	Features []GeoJsonFeature `json:"features"`
+	GeoJsonFeature
+	GeoJsonGeometry
+}
+
+type GeoJsonFeature struct {
+	ID         any              `json:"id"`
+	Geometry   *GeoJsonGeometry `json:"geometry"`
+	Properties map[string]any   `json:"properties"`
+}
+
+type GeoJsonGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []GeoJsonGeometry `json:"geometries"`
+}
+
+// decodeGeoJson reads the feature chosen by its index or name, or the first feature with a line when it's empty
+func decodeGeoJson(input io.Reader, selector string) (Track, error) {
+	var file GeoJsonFile
+	if err := json.NewDecoder(input).Decode(&file); err != nil {
+		return Track{}, err
+	}
+
+	feature, err := file.feature(selector)
+	if err != nil {
+		return Track{}, err
+	}
+	lines, err := feature.Geometry.lines()
+	if err != nil {
+		return Track{}, err
+	}
+
+	track := Track{Name: feature.name(), MaxSpeed: feature.speed(), Elevations: true}
+	for _, line := range lines {
+		for _, coordinates := range line {
+			if len(coordinates) < 2 {
+				continue // Skip invalid coordinates
+			}
+			point := Point{Lat: coordinates[1], Lon: coordinates[0]}
+			if len(coordinates) > 2 {
+				point.Elevation = coordinates[2]
+			} else {
+				track.Elevations = false
+			}
+			track.Points = append(track.Points, point)
+		}
+	}
+	if len(track.Points) == 0 {
+		return Track{}, errors.New("no LineString or MultiLineString coordinates")
+	}
+	return track, nil
+}
+
+func (f GeoJsonFile) feature(selector string) (GeoJsonFeature, error) {
+	var features []GeoJsonFeature
+	switch f.Type {
+	case "FeatureCollection":
+		features = f.Features
+	case "Feature":
+		features = []GeoJsonFeature{f.GeoJsonFeature}
+	default:
+		// the type of the file shadows the geometry one
+		geometry := f.GeoJsonGeometry
+		geometry.Type = f.Type
+		features = []GeoJsonFeature{{Geometry: &geometry}}
+	}
+
+	if selector == "" {
+		for _, feature := range features {
+			if feature.Geometry.isLine() {
+				return feature, nil
+			}
+		}
+		return GeoJsonFeature{}, errors.New("no feature with a LineString or MultiLineString geometry")
+	}
+	if index, err := strconv.Atoi(selector); err == nil {
+		if index < 0 || index >= len(features) {
+			return GeoJsonFeature{}, fmt.Errorf("feature index %d is out of 0..%d", index, len(features)-1)
+		}
+		return features[index], nil
+	}
+	for _, feature := range features {
+		if feature.name() == selector || fmt.Sprint(feature.ID) == selector {
+			return feature, nil
+		}
+	}
+	return GeoJsonFeature{}, fmt.Errorf("no feature named %q", selector)
+}
+
+// property returns the property with the key in any case, QGIS keeps the case of the layer fields
+func (f GeoJsonFeature) property(keys ...string) any {
+	for _, key := range keys {
+		for name, value := range f.Properties {
+			if strings.EqualFold(name, key) {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+func (f GeoJsonFeature) name() string {
+	if name, ok := f.property("name", "title").(string); ok {
+		return name
+	}
+	return ""
+}
+
+// speed returns the speed property in km/h, a number or a numeric string
+func (f GeoJsonFeature) speed() uint {
+	var speed float64
+	switch value := f.property("speed", "maxSpeed", "max_speed").(type) {
+	case float64:
+		speed = value
+	case string:
+		speed, _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	}
+	if speed <= 0 {
+		return 0
+	}
+	return uint(math.Round(speed))
+}
+
+func (g *GeoJsonGeometry) isLine() bool {
+	if g == nil {
+		return false
+	}
+	switch g.Type {
+	case "LineString", "MultiLineString":
+		return true
+	case "GeometryCollection":
+		for _, geometry := range g.Geometries {
+			if geometry.isLine() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lines returns the coordinates of the lines of the geometry, the other geometries of a collection are skipped
+func (g *GeoJsonGeometry) lines() ([][][]float64, error) {
+	if !g.isLine() {
+		return nil, errors.New("the feature has no LineString or MultiLineString geometry")
+	}
+	switch g.Type {
+	case "LineString":
+		var line [][]float64
+		if err := json.Unmarshal(g.Coordinates, &line); err != nil {
+			return nil, fmt.Errorf("invalid LineString coordinates: %w", err)
+		}
+		return [][][]float64{line}, nil
+	case "MultiLineString":
+		var lines [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &lines); err != nil {
+			return nil, fmt.Errorf("invalid MultiLineString coordinates: %w", err)
+		}
+		return lines, nil
+	}
+	var lines [][][]float64
+	for _, geometry := range g.Geometries {
+		if !geometry.isLine() {
+			continue
+		}
+		geometryLines, err := geometry.lines()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, geometryLines...)
+	}
+	return lines, nil
+}
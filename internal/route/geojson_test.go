@@ -0,0 +1,79 @@
+package route
+
+import (
+	"strings"
+	"testing"
+)
+
+const geoJsonCollection = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "id": "start", "geometry": {"type": "Point", "coordinates": [8.0, 47.0]}, "properties": {"name": "Start"}},
+    {"type": "Feature", "id": "north-loop", "geometry": {"type": "LineString", "coordinates": [[8.1, 47.1, 500], [8.2, 47.2, 510.5]]},
+      "properties": {"Name": "Line", "SPEED": "80 "}},
+    {"type": "Feature", "geometry": {"type": "MultiLineString", "coordinates": [[[8.3, 47.3]], [[8.4, 47.4], [8.5, 47.5]]]},
+      "properties": {"title": "Multi", "max_speed": 49.6}},
+    {"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": [
+      {"type": "Point", "coordinates": [9.0, 48.0]},
+      {"type": "LineString", "coordinates": [[8.6, 47.6, 600], [8.7, 47.7, 610]]},
+      {"type": "GeometryCollection", "geometries": [{"type": "LineString", "coordinates": [[8.8, 47.8, 620], [8.9]]}]}
+    ]}, "properties": null},
+    {"type": "Feature", "geometry": null, "properties": {"name": "Nothing"}}
+  ]
+}`
+
+func TestDecodeGeoJson(t *testing.T) {
+	tests := []struct {
+		name       string
+		geoJson    string
+		selector   string
+		trackName  string
+		maxSpeed   uint
+		points     [][2]float64
+		elevations bool
+		err        string
+	}{
+		{name: "first line", geoJson: geoJsonCollection, trackName: "Line", maxSpeed: 80, points: [][2]float64{{47.1, 8.1}, {47.2, 8.2}}, elevations: true},
+		{name: "by index", geoJson: geoJsonCollection, selector: "2", trackName: "Multi", maxSpeed: 50, points: [][2]float64{{47.3, 8.3}, {47.4, 8.4}, {47.5, 8.5}}},
+		{name: "by name", geoJson: geoJsonCollection, selector: "Multi", trackName: "Multi", maxSpeed: 50, points: [][2]float64{{47.3, 8.3}, {47.4, 8.4}, {47.5, 8.5}}},
+		{name: "by id", geoJson: geoJsonCollection, selector: "north-loop", trackName: "Line", maxSpeed: 80, points: [][2]float64{{47.1, 8.1}, {47.2, 8.2}}, elevations: true},
+		{name: "geometry collection", geoJson: geoJsonCollection, selector: "3", points: [][2]float64{{47.6, 8.6}, {47.7, 8.7}, {47.8, 8.8}}, elevations: true},
+		{name: "single feature", geoJson: `{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[8.1, 47.1], [8.2, 47.2]]}, "properties": {"name": "Feature", "speed": 0}}`,
+			trackName: "Feature", points: [][2]float64{{47.1, 8.1}, {47.2, 8.2}}},
+		{name: "bare geometry", geoJson: `{"type": "LineString", "coordinates": [[8.1, 47.1, 1], [8.2, 47.2, 2]]}`, points: [][2]float64{{47.1, 8.1}, {47.2, 8.2}}, elevations: true},
+		{name: "index out of range", geoJson: geoJsonCollection, selector: "5", err: "feature index 5 is out of 0..4"},
+		{name: "unknown name", geoJson: geoJsonCollection, selector: "Finish", err: `no feature named "Finish"`},
+		{name: "selected point", geoJson: geoJsonCollection, selector: "start", err: "no LineString or MultiLineString geometry"},
+		{name: "selected feature without geometry", geoJson: geoJsonCollection, selector: "Nothing", err: "no LineString or MultiLineString geometry"},
+		{name: "no lines", geoJson: `{"type": "Point", "coordinates": [8, 47]}`, err: "no feature with a LineString"},
+		{name: "no coordinates", geoJson: `{"type": "LineString", "coordinates": [[8.1]]}`, err: "no LineString or MultiLineString coordinates"},
+		{name: "invalid coordinates", geoJson: `{"type": "MultiLineString", "coordinates": [[8.1, 47.1]]}`, err: "invalid MultiLineString coordinates"},
+		{name: "not JSON", geoJson: `<gpx/>`, err: "invalid character"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			track, err := decodeGeoJson(strings.NewReader(test.geoJson), test.selector)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if track.Name != test.trackName || track.MaxSpeed != test.maxSpeed || track.Elevations != test.elevations {
+				t.Errorf("got name %q, max speed %d and elevations %v, want %q, %d and %v",
+					track.Name, track.MaxSpeed, track.Elevations, test.trackName, test.maxSpeed, test.elevations)
+			}
+			if len(track.Points) != len(test.points) {
+				t.Fatalf("got %d points, want %d", len(track.Points), len(test.points))
+			}
+			for i, want := range test.points {
+				if point := track.Points[i]; point.Lat != want[0] || point.Lon != want[1] {
+					t.Errorf("point %d: got %f,%f, want %f,%f", i, point.Lat, point.Lon, want[0], want[1])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package route
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+type gpxFile struct {
+	XMLName  xml.Name     `xml:"gpx"`
+	Xmlns    string       `xml:"xmlns,attr,omitempty"`
+	Version  string       `xml:"version,attr,omitempty"`
+	Creator  string       `xml:"creator,attr,omitempty"`
+	Metadata *gpxMetadata `xml:"metadata,omitempty"`
+	Routes   []gpxRoute   `xml:"rte"`
+	Tracks   []gpxTrack   `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name,omitempty"`
+}
+
+type gpxRoute struct {
+	Name   string     `xml:"name,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat       float64    `xml:"lat,attr"`
+	Lon       float64    `xml:"lon,attr"`
+	Elevation *float64   `xml:"ele,omitempty"`
+	Time      *time.Time `xml:"time,omitempty"`
+}
+
+// decodeGpx reads the points of the tracks, all their segments joined, or of the routes when there are no tracks
+func decodeGpx(input io.Reader) (Track, error) {
+	var file gpxFile
+	if err := xml.NewDecoder(input).Decode(&file); err != nil {
+		return Track{}, err
+	}
+
+	track := Track{}
+	if file.Metadata != nil {
+		track.Name = file.Metadata.Name
+	}
+	var points []gpxPoint
+	for _, trk := range file.Tracks {
+		track.Name = cmp.Or(track.Name, trk.Name)
+		for _, segment := range trk.Segments {
+			points = append(points, segment.Points...)
+		}
+	}
+	if len(points) == 0 {
+		for _, rte := range file.Routes {
+			track.Name = cmp.Or(track.Name, rte.Name)
+			points = append(points, rte.Points...)
+		}
+	}
+	if len(points) == 0 {
+		return Track{}, fmt.Errorf("no track or route points")
+	}
+
+	track.Elevations = true
+	track.Points = make([]Point, 0, len(points))
+	for _, gpxPoint := range points {
+		point := Point{Lat: gpxPoint.Lat, Lon: gpxPoint.Lon, Timestamp: gpxPoint.Time}
+		if gpxPoint.Elevation != nil {
+			point.Elevation = *gpxPoint.Elevation
+		} else {
+			track.Elevations = false
+		}
+		track.Points = append(track.Points, point)
+	}
+	return track, nil
+}
+
+// encodeGpx writes the route as a track with the times of the points
+func encodeGpx(output io.Writer, route Route, start time.Time) error {
+	times := pointTimes(route, start)
+
+	segment := gpxSegment{Points: make([]gpxPoint, len(route.Points))}
+	for i, point := range route.Points {
+		segment.Points[i] = gpxPoint{
+			Lat:       point.Lat,
+			Lon:       point.Lon,
+			Elevation: &point.Elevation,
+			Time:      &times[i],
+		}
+	}
+	file := gpxFile{
+		Xmlns:    gpxNamespace,
+		Version:  "1.1",
+		Creator:  "gpsd-simulator",
+		Metadata: &gpxMetadata{Name: route.Name},
+		Tracks:   []gpxTrack{{Name: route.Name, Segments: []gpxSegment{segment}}},
+	}
+
+	if _, err := io.WriteString(output, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(output)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	_, err := io.WriteString(output, "\n")
+	return err
+}
+
+// pointTimes returns the recorded times of the points, or the times they are driven at by the speed profile from
+// the start when some of them have none
+func pointTimes(route Route, start time.Time) []time.Time {
+	times := make([]time.Time, len(route.Points))
+	if recordedTimes(route.Points) {
+		for i, point := range route.Points {
+			times[i] = point.Timestamp.UTC()
+		}
+		return times
+	}
+	timeline := newTimeline(route.Points)
+	for i := range route.Points {
+		times[i] = start.Add(time.Duration(timeline.times[i] * float64(time.Second))).UTC()
+	}
+	return times
+}
+
+// recordedTimes tells whether every point has the recorded time
+func recordedTimes(points []Point) bool {
+	for _, point := range points {
+		if point.Timestamp == nil {
+			return false
+		}
+	}
+	return len(points) > 0
+}
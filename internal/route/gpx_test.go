@@ -0,0 +1,133 @@
+package route
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var exportStart = time.Date(2025, time.June, 13, 17, 29, 0, 0, time.UTC)
+
+// recordedRoute returns the route with the recorded times, elevations, speeds and tracks of the points
+func recordedRoute() Route {
+	points := line(30, []float64{0, 250, 1000}, []float64{0, 12.5, 8.25})
+	for i := range points {
+		timestamp := exportStart.Add(time.Duration(i*40) * time.Second).Add(250 * time.Millisecond)
+		points[i].Timestamp = &timestamp
+		points[i].Elevation = 575.5 - float64(i)*10
+		points[i].Track = 30.5
+	}
+	return Route{Name: "Zürich <Airport> & back", Points: points}
+}
+
+// sameTrack compares the decoded points with the encoded ones
+func sameTrack(t *testing.T, track Track, route Route) {
+	t.Helper()
+	if track.Name != route.Name {
+		t.Errorf("got name %q, want %q", track.Name, route.Name)
+	}
+	if len(track.Points) != len(route.Points) {
+		t.Fatalf("got %d points, want %d", len(track.Points), len(route.Points))
+	}
+	for i, point := range track.Points {
+		want := route.Points[i]
+		if point.Lat != want.Lat || point.Lon != want.Lon || point.Elevation != want.Elevation {
+			t.Errorf("point %d: got %f,%f at %f m, want %f,%f at %f m", i, point.Lat, point.Lon, point.Elevation, want.Lat, want.Lon, want.Elevation)
+		}
+		if point.Timestamp == nil || !point.Timestamp.Equal(*want.Timestamp) {
+			t.Errorf("point %d: got time %v, want %v", i, point.Timestamp, *want.Timestamp)
+		}
+	}
+}
+
+func TestGpxRoundTrip(t *testing.T) {
+	route := recordedRoute()
+	var output bytes.Buffer
+	if err := encodeGpx(&output, route, exportStart.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	track, err := decodeGpx(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameTrack(t, track, route)
+	if !track.Elevations {
+		t.Error("got no elevations")
+	}
+}
+
+// TestGpxTimes checks the points without the recorded times are exported at the times they are driven at
+func TestGpxTimes(t *testing.T) {
+	route := Route{Points: line(90, []float64{0, 1000, 1500}, []float64{10, 10, 0})}
+	var output bytes.Buffer
+	if err := encodeGpx(&output, route, exportStart); err != nil {
+		t.Fatal(err)
+	}
+	track, err := decodeGpx(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, offset := range []time.Duration{0, 100 * time.Second, 200 * time.Second} {
+		want := exportStart.Add(offset)
+		if point := track.Points[i]; point.Timestamp == nil || point.Timestamp.Sub(want).Abs() > time.Millisecond {
+			t.Errorf("point %d: got time %v, want %v", i, track.Points[i].Timestamp, want)
+		}
+	}
+}
+
+func TestDecodeGpx(t *testing.T) {
+	tests := []struct {
+		name       string
+		gpx        string
+		trackName  string
+		lats       []float64
+		elevations bool
+		err        string
+	}{
+		{
+			name: "track segments joined, routes skipped",
+			gpx: `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><rte><rtept lat="1" lon="1"/></rte>
+<trk><name>Track</name><trkseg><trkpt lat="47.1" lon="8.1"><ele>500</ele><time>2025-06-13T17:29:00Z</time></trkpt></trkseg>
+<trkseg><trkpt lat="47.2" lon="8.2"><ele>510.5</ele></trkpt></trkseg></trk></gpx>`,
+			trackName:  "Track",
+			lats:       []float64{47.1, 47.2},
+			elevations: true,
+		},
+		{
+			name: "route without elevations",
+			gpx: `<gpx><metadata><name>Metadata</name></metadata><rte><name>Route</name>
+<rtept lat="-33.8" lon="151.2"><ele>3</ele></rtept><rtept lat="-33.9" lon="151.3"/></rte></gpx>`,
+			trackName: "Metadata",
+			lats:      []float64{-33.8, -33.9},
+		},
+		{name: "no points", gpx: `<gpx><trk><trkseg/></trk></gpx>`, err: "no track or route points"},
+		{name: "not XML", gpx: `{"type": "LineString"}`, err: "EOF"},
+		{name: "invalid coordinates", gpx: `<gpx><trk><trkseg><trkpt lat="north" lon="8"/></trkseg></trk></gpx>`, err: "invalid syntax"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			track, err := decodeGpx(strings.NewReader(test.gpx))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if track.Name != test.trackName || track.Elevations != test.elevations || track.Speeds {
+				t.Errorf("got name %q, elevations %v and speeds %v", track.Name, track.Elevations, track.Speeds)
+			}
+			if len(track.Points) != len(test.lats) {
+				t.Fatalf("got %d points, want %d", len(track.Points), len(test.lats))
+			}
+			for i, lat := range test.lats {
+				if track.Points[i].Lat != lat {
+					t.Errorf("point %d: got latitude %f, want %f", i, track.Points[i].Lat, lat)
+				}
+			}
+		})
+	}
+}
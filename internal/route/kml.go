@@ -0,0 +1,276 @@
+package route
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kmlNamespace   = "http://www.opengis.net/kml/2.2"
+	kmlGxNamespace = "http://www.google.com/kml/ext/2.2"
+)
+
+// kmlGeometry is a LineString or a gx:Track, the altitudes are elevations only in the absolute altitude mode
+type kmlGeometry struct {
+	points       []Point
+	altitudes    bool
+	altitudeMode string
+}
+
+// decodeKml reads the points of the gx:Track elements, all of them joined, or of the LineString elements when
+// there are no tracks, wherever they are in the document
+func decodeKml(input io.Reader) (Track, error) {
+	decoder := xml.NewDecoder(input)
+	var track Track
+	var lineStrings, tracks []kmlGeometry
+	var geometry kmlGeometry
+	var whens []time.Time
+	var elements []string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Track{}, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			elements = append(elements, token.Name.Local)
+			text.Reset()
+			switch token.Name.Local {
+			case "LineString", "Track":
+				geometry = kmlGeometry{altitudes: true}
+				whens = nil
+			}
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			elements = elements[:len(elements)-1]
+			var parent string
+			if len(elements) > 0 {
+				parent = elements[len(elements)-1]
+			}
+			inGeometry := parent == "LineString" || parent == "Track"
+			value := strings.TrimSpace(text.String())
+			text.Reset()
+			switch token.Name.Local {
+			case "name":
+				if track.Name == "" {
+					track.Name = value
+				}
+			case "altitudeMode":
+				if inGeometry {
+					geometry.altitudeMode = value
+				}
+			case "coordinates":
+				if parent != "LineString" {
+					continue
+				}
+				for _, tuple := range strings.Fields(value) {
+					if err = geometry.add(strings.Split(tuple, ",")); err != nil {
+						return Track{}, err
+					}
+				}
+			case "coord":
+				if parent != "Track" {
+					continue
+				}
+				if err = geometry.add(strings.Fields(value)); err != nil {
+					return Track{}, err
+				}
+			case "when":
+				if parent != "Track" {
+					continue
+				}
+				when, err := time.Parse(time.RFC3339Nano, value)
+				if err != nil {
+					return Track{}, fmt.Errorf("invalid gx:Track time %q: %w", value, err)
+				}
+				whens = append(whens, when)
+			case "LineString":
+				lineStrings = append(lineStrings, geometry)
+			case "Track":
+				// the times are kept only when every coordinate has one
+				if len(whens) == len(geometry.points) {
+					for i := range geometry.points {
+						geometry.points[i].Timestamp = &whens[i]
+					}
+				}
+				tracks = append(tracks, geometry)
+			}
+		}
+	}
+
+	geometries := tracks
+	if len(geometries) == 0 {
+		geometries = lineStrings
+	}
+	track.Elevations = len(geometries) > 0
+	for _, geometry := range geometries {
+		track.Points = append(track.Points, geometry.points...)
+		track.Elevations = track.Elevations && geometry.altitudes && geometry.altitudeMode == "absolute"
+	}
+	if len(track.Points) == 0 {
+		return Track{}, errors.New("no gx:Track or LineString points")
+	}
+	return track, nil
+}
+
+// add appends the point from the longitude, latitude and the optional altitude
+func (g *kmlGeometry) add(values []string) error {
+	if len(values) < 2 {
+		return fmt.Errorf("invalid KML coordinates %q", strings.Join(values, ","))
+	}
+	var coordinates [3]float64
+	for i := 0; i < len(values) && i < len(coordinates); i++ {
+		var err error
+		if coordinates[i], err = strconv.ParseFloat(values[i], 64); err != nil {
+			return fmt.Errorf("invalid KML coordinates %q: %w", strings.Join(values, ","), err)
+		}
+	}
+	g.altitudes = g.altitudes && len(values) > 2
+	g.points = append(g.points, Point{Lat: coordinates[1], Lon: coordinates[0], Elevation: coordinates[2]})
+	return nil
+}
+
+// decodeKmz reads the first KML file of the archive, the main one by the KMZ convention
+func decodeKmz(input io.ReaderAt, size int64) (Track, error) {
+	archive, err := zip.NewReader(input, size)
+	if err != nil {
+		return Track{}, err
+	}
+	for _, file := range archive.File {
+		if !strings.EqualFold(filepath.Ext(file.Name), ".kml") {
+			continue
+		}
+		kml, err := file.Open()
+		if err != nil {
+			return Track{}, err
+		}
+		defer kml.Close()
+		return decodeKml(kml)
+	}
+	return Track{}, errors.New("no KML file in the KMZ archive")
+}
+
+type kmlFile struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	XmlnsGx  string      `xml:"xmlns:gx,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name      string       `xml:"name"`
+	Schema    kmlSchema    `xml:"Schema"`
+	Placemark kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlSchema struct {
+	ID     string           `xml:"id,attr"`
+	Fields []kmlSchemaField `xml:"gx:SimpleArrayField"`
+}
+
+type kmlSchemaField struct {
+	Name        string `xml:"name,attr"`
+	Type        string `xml:"type,attr"`
+	DisplayName string `xml:"displayName"`
+}
+
+type kmlPlacemark struct {
+	Name  string   `xml:"name"`
+	Track kmlTrack `xml:"gx:Track"`
+}
+
+type kmlTrack struct {
+	AltitudeMode string          `xml:"altitudeMode"`
+	Whens        []string        `xml:"when"`
+	Coords       []string        `xml:"gx:coord"`
+	Data         kmlExtendedData `xml:"ExtendedData"`
+}
+
+type kmlExtendedData struct {
+	SchemaData struct {
+		SchemaURL string         `xml:"schemaUrl,attr"`
+		Arrays    []kmlArrayData `xml:"gx:SimpleArrayData"`
+	} `xml:"SchemaData"`
+}
+
+type kmlArrayData struct {
+	Name   string   `xml:"name,attr"`
+	Values []string `xml:"gx:value"`
+}
+
+// encodeKml writes the route as a gx:Track with the speed and the course of every point
+func encodeKml(output io.Writer, route Route, start time.Time) error {
+	times := pointTimes(route, start)
+	track := kmlTrack{AltitudeMode: "absolute"}
+	speeds := kmlArrayData{Name: "speed"}
+	courses := kmlArrayData{Name: "course"}
+	for i, point := range route.Points {
+		track.Whens = append(track.Whens, times[i].Format(time.RFC3339Nano))
+		track.Coords = append(track.Coords, fmt.Sprintf("%s %s %s", formatFloat(point.Lon), formatFloat(point.Lat), formatFloat(point.Elevation)))
+		speeds.Values = append(speeds.Values, strconv.FormatFloat(point.Speed, 'f', 2, 64))
+		courses.Values = append(courses.Values, strconv.FormatFloat(point.Track, 'f', 1, 64))
+	}
+	track.Data.SchemaData.SchemaURL = "#point"
+	track.Data.SchemaData.Arrays = []kmlArrayData{speeds, courses}
+
+	file := kmlFile{
+		Xmlns:   kmlNamespace,
+		XmlnsGx: kmlGxNamespace,
+		Document: kmlDocument{
+			Name: route.Name,
+			Schema: kmlSchema{
+				ID: "point",
+				Fields: []kmlSchemaField{
+					{Name: "speed", Type: "float", DisplayName: "Speed, m/s"},
+					{Name: "course", Type: "float", DisplayName: "Course, °"},
+				},
+			},
+			Placemark: kmlPlacemark{Name: route.Name, Track: track},
+		},
+	}
+
+	if _, err := io.WriteString(output, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(output)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	_, err := io.WriteString(output, "\n")
+	return err
+}
+
+// encodeKmz writes the KML of the route as doc.kml of the archive
+func encodeKmz(output io.Writer, route Route, start time.Time) error {
+	var kml bytes.Buffer
+	if err := encodeKml(&kml, route, start); err != nil {
+		return err
+	}
+	archive := zip.NewWriter(output)
+	file, err := archive.Create("doc.kml")
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(kml.Bytes()); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,137 @@
+package route
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKmlRoundTrip(t *testing.T) {
+	route := recordedRoute()
+	var output bytes.Buffer
+	if err := encodeKml(&output, route, exportStart); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"<gx:value>12.50</gx:value>", "<gx:value>30.5</gx:value>"} {
+		if !strings.Contains(output.String(), value) {
+			t.Errorf("no %s speed or course in\n%s", value, output.String())
+		}
+	}
+	track, err := decodeKml(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameTrack(t, track, route)
+	if !track.Elevations {
+		t.Error("got no elevations in the absolute altitude mode")
+	}
+}
+
+func TestKmzRoundTrip(t *testing.T) {
+	route := recordedRoute()
+	var output bytes.Buffer
+	if err := encodeKmz(&output, route, exportStart); err != nil {
+		t.Fatal(err)
+	}
+	track, err := decodeKmz(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameTrack(t, track, route)
+
+	var empty bytes.Buffer
+	archive := zip.NewWriter(&empty)
+	if _, err = archive.Create("images/icon.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err = archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decodeKmz(bytes.NewReader(empty.Bytes()), int64(empty.Len())); err == nil || !strings.Contains(err.Error(), "no KML file") {
+		t.Errorf("got error %v, want no KML file", err)
+	}
+}
+
+func TestDecodeKml(t *testing.T) {
+	tests := []struct {
+		name       string
+		kml        string
+		trackName  string
+		points     [][2]float64
+		elevations bool
+		timestamps bool
+		err        string
+	}{
+		{
+			name: "line strings joined",
+			kml: `<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>Document</name><Folder><Placemark><name>Line</name>
+<LineString><altitudeMode>absolute</altitudeMode><coordinates>8.1,47.1,500
+  8.2,47.2,510.5</coordinates></LineString></Placemark></Folder>
+<Placemark><MultiGeometry><LineString><altitudeMode>absolute</altitudeMode><coordinates>8.3,47.3,520</coordinates></LineString></MultiGeometry></Placemark>
+<Placemark><Point><coordinates>9,48,0</coordinates></Point></Placemark></Document></kml>`,
+			trackName:  "Document",
+			points:     [][2]float64{{47.1, 8.1}, {47.2, 8.2}, {47.3, 8.3}},
+			elevations: true,
+		},
+		{
+			name:   "altitudes relative to the ground",
+			kml:    `<kml><Placemark><LineString><coordinates>8.1,47.1,5 8.2,47.2,5</coordinates></LineString></Placemark></kml>`,
+			points: [][2]float64{{47.1, 8.1}, {47.2, 8.2}},
+		},
+		{
+			name:   "no altitudes",
+			kml:    `<kml><Placemark><LineString><altitudeMode>absolute</altitudeMode><coordinates>8.1,47.1 8.2,47.2,5</coordinates></LineString></Placemark></kml>`,
+			points: [][2]float64{{47.1, 8.1}, {47.2, 8.2}},
+		},
+		{
+			name: "tracks preferred to line strings",
+			kml: `<kml xmlns:gx="http://www.google.com/kml/ext/2.2"><Placemark><LineString><coordinates>1,1,1</coordinates></LineString></Placemark>
+<Placemark><name>Track</name><gx:Track><altitudeMode>absolute</altitudeMode><when>2025-06-13T17:29:00Z</when><when>2025-06-13T17:29:01.5Z</when>
+<gx:coord>8.1 47.1 500</gx:coord><gx:coord>8.2 47.2 510</gx:coord></gx:Track></Placemark></kml>`,
+			trackName:  "Track",
+			points:     [][2]float64{{47.1, 8.1}, {47.2, 8.2}},
+			elevations: true,
+			timestamps: true,
+		},
+		{
+			name: "track with missing times",
+			kml: `<kml><Placemark><gx:Track><when>2025-06-13T17:29:00Z</when>
+<gx:coord>8.1 47.1 500</gx:coord><gx:coord>8.2 47.2 510</gx:coord></gx:Track></Placemark></kml>`,
+			points: [][2]float64{{47.1, 8.1}, {47.2, 8.2}},
+		},
+		{name: "no lines", kml: `<kml><Placemark><Point><coordinates>9,48,0</coordinates></Point></Placemark></kml>`, err: "no gx:Track or LineString points"},
+		{name: "invalid coordinates", kml: `<kml><LineString><coordinates>8.1;47.1</coordinates></LineString></kml>`, err: "invalid KML coordinates"},
+		{name: "invalid time", kml: `<kml><gx:Track><when>yesterday</when></gx:Track></kml>`, err: "invalid gx:Track time"},
+		{name: "not XML", kml: `<kml><LineString>`, err: "EOF"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			track, err := decodeKml(strings.NewReader(test.kml))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if track.Name != test.trackName || track.Elevations != test.elevations {
+				t.Errorf("got name %q and elevations %v, want %q and %v", track.Name, track.Elevations, test.trackName, test.elevations)
+			}
+			if len(track.Points) != len(test.points) {
+				t.Fatalf("got %d points, want %d", len(track.Points), len(test.points))
+			}
+			for i, want := range test.points {
+				point := track.Points[i]
+				if point.Lat != want[0] || point.Lon != want[1] {
+					t.Errorf("point %d: got %f,%f, want %f,%f", i, point.Lat, point.Lon, want[0], want[1])
+				}
+				if (point.Timestamp != nil) != test.timestamps {
+					t.Errorf("point %d: got time %v", i, point.Timestamp)
+				}
+			}
+		})
+	}
+}
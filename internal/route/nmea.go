@@ -0,0 +1,364 @@
+package route
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+const metersPerSecondPerKnot = 1852.0 / 3600
+
+// nmeaEpoch collects the sentences of one output cycle of the receiver, the timed ones share the time of the fix
+// and GSA and GSV belong to the cycle they follow
+type nmeaEpoch struct {
+	timeOfDay  time.Duration
+	date       time.Time
+	lat, lon   float64
+	position   bool
+	altitude   float64
+	hasAlt     bool
+	speed      float64
+	track      float64
+	hasSpeed   bool
+	invalid    bool
+	quality    int
+	mode       int
+	satellites []sky.Satellite
+	used       map[[2]int]bool
+}
+
+// decodeNmea builds the track of the epochs of an NMEA log from the GGA, RMC, GSA, GSV and ZDA sentences, the other
+// sentences and the ones with a wrong checksum are skipped. The epochs without a fix stay at the last position.
+func decodeNmea(input io.Reader) (Track, error) {
+	var epochs []*nmeaEpoch
+	var epoch *nmeaEpoch
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		talker, kind, fields, ok := parseNmeaSentence(scanner.Text())
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "GGA", "RMC", "ZDA":
+			timeOfDay, ok := parseNmeaTime(nmeaField(fields, 1))
+			if !ok {
+				continue
+			}
+			if epoch == nil || epoch.timeOfDay != timeOfDay {
+				epoch = &nmeaEpoch{timeOfDay: timeOfDay, quality: -1, used: make(map[[2]int]bool)}
+				epochs = append(epochs, epoch)
+			}
+		case "GSA", "GSV":
+			if epoch == nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		switch kind {
+		case "GGA":
+			epoch.gga(fields)
+		case "RMC":
+			epoch.rmc(fields)
+		case "ZDA":
+			epoch.zda(fields)
+		case "GSA":
+			epoch.gsa(talker, fields)
+		case "GSV":
+			epoch.gsv(talker, fields)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Track{}, err
+	}
+
+	return nmeaTrack(epochs)
+}
+
+func nmeaTrack(epochs []*nmeaEpoch) (Track, error) {
+	// the sentences before the first date get it too, the date changes at midnight when it isn't reported
+	var day time.Time
+	for _, epoch := range epochs {
+		if !epoch.date.IsZero() {
+			day = epoch.date
+			break
+		}
+	}
+
+	track := Track{Elevations: true, Speeds: true}
+	var last *Point
+	previous := time.Duration(-1)
+	for _, epoch := range epochs {
+		if !epoch.date.IsZero() {
+			day = epoch.date
+		} else if epoch.timeOfDay < previous-12*time.Hour {
+			day = day.AddDate(0, 0, 1)
+		}
+		previous = epoch.timeOfDay
+		timestamp := day.Add(epoch.timeOfDay)
+
+		mode := epoch.fixMode()
+		var point Point
+		switch {
+		case mode != 1:
+			point = Point{Lat: epoch.lat, Lon: epoch.lon, Elevation: epoch.altitude, Speed: epoch.speed, Track: epoch.track}
+			track.Elevations = track.Elevations && epoch.hasAlt
+			track.Speeds = track.Speeds && epoch.hasSpeed
+		case !epoch.lost():
+			// a truncated sentence tells neither the position nor that the fix is lost
+			continue
+		case last != nil:
+			point = Point{Lat: last.Lat, Lon: last.Lon, Elevation: last.Elevation, Track: last.Track}
+		default:
+			// there is no position before the first fix
+			continue
+		}
+		point.Timestamp = &timestamp
+		point.Mode = mode
+		point.Status = epoch.status()
+		point.Satellites = epoch.sky()
+		track.Points = append(track.Points, point)
+		last = &track.Points[len(track.Points)-1]
+	}
+	if len(track.Points) == 0 {
+		return Track{}, errors.New("no GGA or RMC fixes")
+	}
+	return track, nil
+}
+
+// $GPGGA,172900.00,4722.91058,N,00826.89476,E,1,08,1.0,575.0,M,48.0,M,,*42
+func (e *nmeaEpoch) gga(fields []string) {
+	if lat, lon, ok := parseNmeaLatLon(fields, 2); ok {
+		e.lat, e.lon, e.position = lat, lon, true
+	}
+	if quality, err := strconv.Atoi(nmeaField(fields, 6)); err == nil {
+		e.quality = quality
+	}
+	if altitude, err := strconv.ParseFloat(nmeaField(fields, 9), 64); err == nil {
+		e.altitude, e.hasAlt = altitude, true
+	}
+}
+
+// $GPRMC,172900.00,A,4722.91058,N,00826.89476,E,29.702,91.1,130625,,,A*40
+func (e *nmeaEpoch) rmc(fields []string) {
+	e.invalid = nmeaField(fields, 2) == "V"
+	if lat, lon, ok := parseNmeaLatLon(fields, 3); ok {
+		e.lat, e.lon, e.position = lat, lon, true
+	}
+	if speed, err := strconv.ParseFloat(nmeaField(fields, 7), 64); err == nil {
+		e.speed, e.hasSpeed = speed*metersPerSecondPerKnot, true
+	}
+	if track, err := strconv.ParseFloat(nmeaField(fields, 8), 64); err == nil {
+		e.track = track
+	}
+	if date, err := time.Parse("020106", nmeaField(fields, 9)); err == nil {
+		e.date = date
+	}
+}
+
+// $GPZDA,172900.00,13,06,2025,00,00*73
+func (e *nmeaEpoch) zda(fields []string) {
+	if date, err := time.Parse("02 01 2006", strings.Join([]string{nmeaField(fields, 2), nmeaField(fields, 3), nmeaField(fields, 4)}, " ")); err == nil {
+		e.date = date
+	}
+}
+
+// $GNGSA,A,3,01,03,,,,,,,,,,,1.8,1.0,1.5,1*22, the system ID of NMEA 4.10 tells the constellation of GNGSA
+func (e *nmeaEpoch) gsa(talker string, fields []string) {
+	if mode, err := strconv.Atoi(nmeaField(fields, 2)); err == nil {
+		e.mode = mode
+	}
+	if talker == "GN" {
+		switch nmeaField(fields, 18) {
+		case "1":
+			talker = "GP"
+		case "2":
+			talker = "GL"
+		case "3":
+			talker = "GA"
+		case "4":
+			talker = "GB"
+		}
+	}
+	for i := 3; i <= 14; i++ {
+		id, err := strconv.Atoi(nmeaField(fields, i))
+		if err != nil {
+			continue
+		}
+		if sat, ok := nmeaSatellite(talker, id); ok {
+			e.used[[2]int{sat.GnssId, sat.NmeaId()}] = true
+		}
+	}
+}
+
+// $GPGSV,3,1,09,01,45,123,42,03,30,045,38,...*77, NMEA 4.10 repeats the satellites for every signal
+func (e *nmeaEpoch) gsv(talker string, fields []string) {
+	for i := 4; i+3 < len(fields); i += 4 {
+		id, err := strconv.Atoi(fields[i])
+		if err != nil {
+			continue
+		}
+		sat, ok := nmeaSatellite(talker, id)
+		if !ok || e.hasSatellite(sat) {
+			continue
+		}
+		sat.Elevation, _ = strconv.ParseFloat(fields[i+1], 64)
+		sat.Azimuth, _ = strconv.ParseFloat(fields[i+2], 64)
+		sat.SNR, _ = strconv.ParseFloat(fields[i+3], 64)
+		e.satellites = append(e.satellites, sat)
+	}
+}
+
+func (e *nmeaEpoch) hasSatellite(sat sky.Satellite) bool {
+	for _, known := range e.satellites {
+		if known.PRN == sat.PRN {
+			return true
+		}
+	}
+	return false
+}
+
+// sky returns the satellites in view marked with the ones used in the fix, nil without GSV
+func (e *nmeaEpoch) sky() []sky.Satellite {
+	for i, sat := range e.satellites {
+		e.satellites[i].Used = e.used[[2]int{sat.GnssId, sat.NmeaId()}]
+	}
+	return e.satellites
+}
+
+// fixMode is the degraded fix mode of the point: 1 without a fix, 2 for a 2D fix and 0 for a 3D fix
+func (e *nmeaEpoch) fixMode() uint {
+	switch {
+	case !e.position || e.invalid || e.quality == 0 || e.mode == 1:
+		return 1
+	case e.mode == 2:
+		return 2
+	}
+	return 0
+}
+
+// lost tells whether the receiver reported that it has no fix
+func (e *nmeaEpoch) lost() bool {
+	return e.invalid || e.quality == 0 || e.mode == 1
+}
+
+// status maps the GGA fix quality to the TPV status, 0 keeps the configured one
+func (e *nmeaEpoch) status() uint {
+	switch e.quality {
+	case 2:
+		return 2 // DGPS
+	case 4:
+		return 3 // RTK fixed
+	case 5:
+		return 4 // RTK float
+	case 6:
+		return 5 // dead reckoning
+	}
+	return 0
+}
+
+// nmeaSatellite converts the satellite number of the talker to the gpsd numbering, GNGSV and GNGSA without the
+// system ID tell GPS and GLONASS apart by their ranges. SBAS and the other satellites aren't supported.
+func nmeaSatellite(talker string, id int) (sky.Satellite, bool) {
+	switch {
+	case (talker == "GP" || talker == "GN") && id >= 1 && id <= 32:
+		return sky.Satellite{PRN: id, GnssId: sky.GnssIdGPS, SvId: id}, true
+	case (talker == "GL" || talker == "GN") && id >= 65 && id <= 96:
+		return sky.Satellite{PRN: id, GnssId: sky.GnssIdGLONASS, SvId: id - 64}, true
+	case talker == "GA" && id >= 1 && id <= 36:
+		return sky.Satellite{PRN: 300 + id, GnssId: sky.GnssIdGalileo, SvId: id}, true
+	case (talker == "GB" || talker == "BD") && id >= 1 && id <= 63:
+		return sky.Satellite{PRN: 400 + id, GnssId: sky.GnssIdBeiDou, SvId: id}, true
+	}
+	return sky.Satellite{}, false
+}
+
+// parseNmeaSentence finds the sentence in the log line and checks its checksum when it's present
+func parseNmeaSentence(line string) (talker, kind string, fields []string, ok bool) {
+	start := strings.IndexByte(line, '$')
+	if start < 0 {
+		return "", "", nil, false
+	}
+	data := strings.TrimSpace(line[start+1:])
+	if end := strings.IndexByte(data, '*'); end >= 0 {
+		checksum, err := strconv.ParseUint(strings.TrimSpace(data[end+1:]), 16, 8)
+		data = data[:end]
+		if err != nil || byte(checksum) != nmeaChecksum(data) {
+			return "", "", nil, false
+		}
+	}
+	fields = strings.Split(data, ",")
+	if len(fields[0]) != 5 {
+		return "", "", nil, false
+	}
+	return fields[0][:2], fields[0][2:], fields, true
+}
+
+func nmeaChecksum(data string) byte {
+	var checksum byte
+	for i := 0; i < len(data); i++ {
+		checksum ^= data[i]
+	}
+	return checksum
+}
+
+func nmeaField(fields []string, i int) string {
+	if i < len(fields) {
+		return strings.TrimSpace(fields[i])
+	}
+	return ""
+}
+
+// parseNmeaTime parses hhmmss.ss into the time since midnight
+func parseNmeaTime(value string) (time.Duration, bool) {
+	if len(value) < 6 {
+		return 0, false
+	}
+	hours, errHours := strconv.Atoi(value[:2])
+	minutes, errMinutes := strconv.Atoi(value[2:4])
+	seconds, errSeconds := strconv.ParseFloat(value[4:], 64)
+	if errHours != nil || errMinutes != nil || errSeconds != nil {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(math.Round(seconds*1000))*time.Millisecond, true
+}
+
+// parseNmeaLatLon parses the ddmm.mmmm,N,dddmm.mmmm,E fields from the index
+func parseNmeaLatLon(fields []string, i int) (float64, float64, bool) {
+	lat, errLat := parseNmeaDegrees(nmeaField(fields, i), 2)
+	lon, errLon := parseNmeaDegrees(nmeaField(fields, i+2), 3)
+	if errLat != nil || errLon != nil {
+		return 0, 0, false
+	}
+	if nmeaField(fields, i+1) == "S" {
+		lat = -lat
+	}
+	if nmeaField(fields, i+3) == "W" {
+		lon = -lon
+	}
+	return lat, lon, true
+}
+
+func parseNmeaDegrees(value string, degreeDigits int) (float64, error) {
+	if len(value) <= degreeDigits {
+		return 0, fmt.Errorf("invalid NMEA coordinate %q", value)
+	}
+	degrees, err := strconv.Atoi(value[:degreeDigits])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseFloat(value[degreeDigits:], 64)
+	if err != nil {
+		return 0, err
+	}
+	return float64(degrees) + minutes/60, nil
+}
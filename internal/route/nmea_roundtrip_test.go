@@ -0,0 +1,80 @@
+package route_test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/nmea"
+	"github.com/aokhrimenko/gpsd-simulator/internal/route"
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+// TestNmeaRoundTrip decodes the log written by the NMEA encoder back to the track
+func TestNmeaRoundTrip(t *testing.T) {
+	satellites := []sky.Satellite{
+		{PRN: 1, GnssId: sky.GnssIdGPS, SvId: 1, Elevation: 45, Azimuth: 123, SNR: 42, Used: true},
+		{PRN: 12, GnssId: sky.GnssIdGPS, SvId: 12, Elevation: 10, Azimuth: 300, SNR: 20},
+		{PRN: 70, GnssId: sky.GnssIdGLONASS, SvId: 6, Elevation: 50, Azimuth: 100, SNR: 40, Used: true},
+		{PRN: 305, GnssId: sky.GnssIdGalileo, SvId: 5, Elevation: 35, Azimuth: 150, SNR: 41, Used: true},
+		{PRN: 419, GnssId: sky.GnssIdBeiDou, SvId: 19, Elevation: 60, Azimuth: 10, SNR: 44, Used: true},
+	}
+	start := time.Date(2025, time.December, 31, 23, 59, 58, 500000000, time.UTC)
+	fixes := []nmea.Fix{
+		{Point: route.Point{Lat: 47.38, Lon: 8.44, Elevation: 575.25, Speed: 15.28, Track: 91.14}, Mode: 3},
+		{Point: route.Point{Lat: -33.868812, Lon: -151.209344, Elevation: -12, Speed: 0.5, Track: 359.9, Status: 2}, Mode: 3},
+		{Point: route.Point{Lat: 0.000001, Lon: 179.999999, Speed: 30, Track: 0, Status: 3}, Mode: 2},
+		{Point: route.Point{Lat: 10, Lon: 10}, Mode: 1},
+		{Point: route.Point{Lat: 89.5, Lon: -0.5, Elevation: 12345.6, Speed: 250, Track: 180, Status: 5}, Mode: 3},
+	}
+
+	encoder, err := nmea.NewEncoder(nmea.Config{Talker: nmea.TalkerGNSS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log strings.Builder
+	for i := range fixes {
+		fixes[i].Time = start.Add(time.Duration(i) * time.Second)
+		fixes[i].Sky = sky.NewView(satellites)
+		for _, sentence := range encoder.Encode(fixes[i]) {
+			log.WriteString(sentence)
+		}
+	}
+
+	track, err := route.DecodeNmea(strings.NewReader(log.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Points) != len(fixes) {
+		t.Fatalf("got %d points, want %d", len(track.Points), len(fixes))
+	}
+	for i, fix := range fixes {
+		point, want := track.Points[i], fix.Point
+		mode := map[uint]uint{1: 1, 2: 2, 3: 0}[fix.Mode]
+		if fix.Mode == 1 {
+			// the lost fix stays at the last position
+			want = fixes[i-1].Point
+			want.Speed, want.Status = 0, 0
+		}
+		if point.Timestamp == nil || !point.Timestamp.Equal(fix.Time) {
+			t.Errorf("point %d: got time %v, want %v", i, point.Timestamp, fix.Time)
+		}
+		if math.Abs(point.Lat-want.Lat) > 1e-6 || math.Abs(point.Lon-want.Lon) > 1e-6 {
+			t.Errorf("point %d: got position %f,%f, want %f,%f", i, point.Lat, point.Lon, want.Lat, want.Lon)
+		}
+		if math.Abs(point.Speed-want.Speed) > 0.001 || math.Abs(point.Track-want.Track) > 0.05 {
+			t.Errorf("point %d: got speed %f and track %f, want %f and %f", i, point.Speed, point.Track, want.Speed, want.Track)
+		}
+		if fix.Mode == 3 && math.Abs(point.Elevation-want.Elevation) > 0.05 {
+			t.Errorf("point %d: got elevation %f, want %f", i, point.Elevation, want.Elevation)
+		}
+		if point.Mode != mode || point.Status != want.Status {
+			t.Errorf("point %d: got mode %d and status %d, want %d and %d", i, point.Mode, point.Status, mode, want.Status)
+		}
+		if fmt.Sprint(point.Satellites) != fmt.Sprint(satellites) {
+			t.Errorf("point %d: got satellites %v, want %v", i, point.Satellites, satellites)
+		}
+	}
+}
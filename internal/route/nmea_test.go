@@ -0,0 +1,125 @@
+package route
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+// nmeaLog adds the checksums to the sentences
+func nmeaLog(sentences ...string) string {
+	var log strings.Builder
+	for _, sentence := range sentences {
+		fmt.Fprintf(&log, "$%s*%02X\r\n", sentence, nmeaChecksum(sentence))
+	}
+	return log.String()
+}
+
+func TestDecodeNmea(t *testing.T) {
+	log := nmeaLog(
+		"GPRMC,235959.00,A,4722.80000,N,00826.40000,E,10.000,90.0,310125,,,D",
+		"GPGGA,235959.00,4722.80000,N,00826.40000,E,2,05,1.2,500.0,M,48.0,M,,",
+		"GNGSA,A,3,01,03,,,,,,,,,,,1.8,1.0,1.5,1",
+		"GNGSA,A,3,65,,,,,,,,,,,,1.8,1.0,1.5,2",
+		"GPGSV,1,1,03,01,45,123,42,03,30,045,38,12,10,300,20",
+		"GLGSV,1,1,01,65,50,100,40",
+		"GAGSV,1,1,01,05,35,150,41,7",
+		"GPRMC,000001.00,V,,,,,,,,,,N",
+		"GPGGA,000001.00,,,,,0,00,,,,,,,",
+		"GPRMC,000003.00,A,4722.80000,S,00826.40000,W,0.000,0.0,,,,A",
+		"GPGGA,000003.00,4722.80000,S,00826.40000,W,4,10,0.6,510.5,M,48.0,M,,",
+	) + "$GPGGA,000004.00,garbage*00\r\n"
+
+	track, err := decodeNmea(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(track.Points) != 3 {
+		t.Fatalf("got %d points, want 3", len(track.Points))
+	}
+	if !track.Elevations || !track.Speeds {
+		t.Errorf("got Elevations %v and Speeds %v, want both", track.Elevations, track.Speeds)
+	}
+
+	times := []time.Time{
+		time.Date(2025, time.January, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2025, time.February, 1, 0, 0, 1, 0, time.UTC),
+		time.Date(2025, time.February, 1, 0, 0, 3, 0, time.UTC),
+	}
+	for i, point := range track.Points {
+		if point.Timestamp == nil || !point.Timestamp.Equal(times[i]) {
+			t.Errorf("point %d: got time %v, want %v", i, point.Timestamp, times[i])
+		}
+	}
+
+	first := track.Points[0]
+	if math.Abs(first.Lat-47.38) > 1e-9 || math.Abs(first.Lon-8.44) > 1e-9 {
+		t.Errorf("got position %f,%f, want 47.38,8.44", first.Lat, first.Lon)
+	}
+	if first.Elevation != 500 || first.Track != 90 || math.Abs(first.Speed-10*metersPerSecondPerKnot) > 1e-9 {
+		t.Errorf("got elevation %f, track %f and speed %f", first.Elevation, first.Track, first.Speed)
+	}
+	if first.Mode != 0 || first.Status != 2 {
+		t.Errorf("got mode %d and status %d, want 0 and 2", first.Mode, first.Status)
+	}
+	wantSatellites := []sky.Satellite{
+		{PRN: 1, GnssId: sky.GnssIdGPS, SvId: 1, Elevation: 45, Azimuth: 123, SNR: 42, Used: true},
+		{PRN: 3, GnssId: sky.GnssIdGPS, SvId: 3, Elevation: 30, Azimuth: 45, SNR: 38, Used: true},
+		{PRN: 12, GnssId: sky.GnssIdGPS, SvId: 12, Elevation: 10, Azimuth: 300, SNR: 20},
+		{PRN: 65, GnssId: sky.GnssIdGLONASS, SvId: 1, Elevation: 50, Azimuth: 100, SNR: 40, Used: true},
+		{PRN: 305, GnssId: sky.GnssIdGalileo, SvId: 5, Elevation: 35, Azimuth: 150, SNR: 41},
+	}
+	if fmt.Sprint(first.Satellites) != fmt.Sprint(wantSatellites) {
+		t.Errorf("got satellites %v, want %v", first.Satellites, wantSatellites)
+	}
+
+	lost := track.Points[1]
+	if lost.Mode != 1 || lost.Lat != first.Lat || lost.Lon != first.Lon || lost.Speed != 0 {
+		t.Errorf("got lost fix %+v, want mode 1 at the last position", lost)
+	}
+
+	last := track.Points[2]
+	if last.Lat >= 0 || last.Lon >= 0 || last.Status != 3 || last.Elevation != 510.5 {
+		t.Errorf("got last point %+v, want the southern and western hemisphere RTK fixed at 510.5 m", last)
+	}
+}
+
+func TestDecodeNmeaMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    string
+		points int
+	}{
+		{"truncated GGA", "$GPGGA,172900.00,4722.91058", 0},
+		{"truncated RMC", "$GPRMC,172900.00", 0},
+		{"truncated GSA", "$GPGGA,172900.00,4722.91058,N,00826.89476,E,1\r\n$GPGSA,A", 1},
+		{"truncated GSV", "$GPGGA,172900.00,4722.91058,N,00826.89476,E,1\r\n$GPGSV,1,1,01,05,40", 1},
+		{"truncated last line", nmeaLog("GPGGA,172900.00,4722.91058,N,00826.89476,E,1,08,1.0,575.0,M,48.0,M,,") + "$GPRMC,172901.00,A,47", 1},
+		{"bad checksum", "$GPGGA,172900.00,4722.91058,N,00826.89476,E,1,08,1.0,575.0,M,48.0,M,,*00", 0},
+		{"bad time", "$GPGGA,17:29,4722.91058,N,00826.89476,E,1", 0},
+		{"bad coordinates", "$GPGGA,172900.00,47,N,8,E,1", 0},
+		{"garbage", "\x00\xff$$$,*\r\n$\r\n$GP\r\n$,,,,\r\nhello", 0},
+		{"empty", "", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			track, err := decodeNmea(strings.NewReader(test.log))
+			if test.points == 0 {
+				if err == nil {
+					t.Errorf("got %d points, want an error", len(track.Points))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(track.Points) != test.points {
+				t.Errorf("got %d points, want %d", len(track.Points), test.points)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package route
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	NoiseModelNone        = "none"
+	NoiseModelWhite       = "white"
+	NoiseModelGaussMarkov = "gauss-markov"
+	NoiseModelMultipath   = "multipath"
+
+	DefaultNoiseCorrelationTime      = 60.0
+	DefaultNoiseMultipathProbability = 0.02
+	DefaultNoiseMultipathJump        = 30.0
+
+	// verticalNoiseFactor is the ratio of the vertical and the horizontal errors, the geometry is always worse vertically
+	verticalNoiseFactor = 1.5
+	// multipathDuration is how many ticks a multipath jump lasts
+	multipathDuration = 3
+)
+
+var NoiseModels = []string{NoiseModelNone, NoiseModelWhite, NoiseModelGaussMarkov, NoiseModelMultipath}
+
+// Noise describes the position error added to the route geometry. White noise is independent on every tick,
+// Gauss-Markov is a first order random walk slowly wandering around the true position and multipath is white
+// noise with occasional jumps away from it. Sigma is the standard deviation of the horizontal error in meters.
+type Noise struct {
+	Model                string  `json:"model"`
+	Sigma                float64 `json:"sigma"`
+	Seed                 int64   `json:"seed,omitempty"`
+	CorrelationTime      float64 `json:"correlationTime,omitempty"`
+	MultipathProbability float64 `json:"multipathProbability,omitempty"`
+	MultipathJump        float64 `json:"multipathJump,omitempty"`
+}
+
+func (n Noise) Validate() error {
+	switch n.Model {
+	case "", NoiseModelNone, NoiseModelWhite, NoiseModelGaussMarkov, NoiseModelMultipath:
+	default:
+		return fmt.Errorf("unsupported noise model %q, expected one of %v", n.Model, NoiseModels)
+	}
+	if n.Sigma < 0 || n.CorrelationTime < 0 || n.MultipathJump < 0 {
+		return fmt.Errorf("noise sigma, correlation time and multipath jump can't be negative")
+	}
+	if n.MultipathProbability < 0 || n.MultipathProbability > 1 {
+		return fmt.Errorf("noise multipath probability %f is out of 0..1", n.MultipathProbability)
+	}
+	return nil
+}
+
+func (n Noise) enabled() bool {
+	return n.Model != "" && n.Model != NoiseModelNone && n.Sigma > 0
+}
+
+func newNoiseGenerator(noise Noise) *noiseGenerator {
+	if noise.CorrelationTime == 0 {
+		noise.CorrelationTime = DefaultNoiseCorrelationTime
+	}
+	if noise.MultipathProbability == 0 {
+		noise.MultipathProbability = DefaultNoiseMultipathProbability
+	}
+	if noise.MultipathJump == 0 {
+		noise.MultipathJump = DefaultNoiseMultipathJump
+	}
+	return &noiseGenerator{noise: noise, random: rand.New(rand.NewSource(noise.Seed))}
+}
+
+// noiseGenerator keeps the state of the error processes between the ticks
+type noiseGenerator struct {
+	noise  Noise
+	random *rand.Rand
+
+	east, north, up     float64
+	jumpEast, jumpNorth float64
+	jumpTicks           int
+}
+
+// apply moves the point by the next error sample and sets the error estimates a receiver would report with it
+func (g *noiseGenerator) apply(point Point, step time.Duration) Point {
+	if !g.noise.enabled() {
+		return point
+	}
+	sigma := g.noise.Sigma
+	sigmaUp := sigma * verticalNoiseFactor
+
+	switch g.noise.Model {
+	case NoiseModelGaussMarkov:
+		correlation := math.Exp(-step.Seconds() / g.noise.CorrelationTime)
+		scale := math.Sqrt(1 - correlation*correlation)
+		g.east = correlation*g.east + sigma*scale*g.random.NormFloat64()
+		g.north = correlation*g.north + sigma*scale*g.random.NormFloat64()
+		g.up = correlation*g.up + sigmaUp*scale*g.random.NormFloat64()
+	default:
+		g.east = sigma * g.random.NormFloat64()
+		g.north = sigma * g.random.NormFloat64()
+		g.up = sigmaUp * g.random.NormFloat64()
+	}
+
+	if g.noise.Model == NoiseModelMultipath {
+		if g.jumpTicks == 0 && g.random.Float64() < g.noise.MultipathProbability {
+			direction := g.random.Float64() * 2 * math.Pi
+			g.jumpEast = g.noise.MultipathJump * math.Sin(direction)
+			g.jumpNorth = g.noise.MultipathJump * math.Cos(direction)
+			g.jumpTicks = multipathDuration
+		}
+		if g.jumpTicks > 0 {
+			g.jumpTicks--
+		} else {
+			g.jumpEast, g.jumpNorth = 0, 0
+		}
+	}
+
+	east := g.east + g.jumpEast
+	north := g.north + g.jumpNorth
+	point.Lat += radiansToDegrees(north / earthRadiusMeters)
+	point.Lon += radiansToDegrees(east / (earthRadiusMeters * math.Cos(degreesToRadians(point.Lat))))
+	point.Elevation += g.up
+
+	// 95% confidence as gpsd reports them, a receiver notices the multipath only partially
+	point.Epx = 2*sigma + math.Abs(g.jumpEast)/2
+	point.Epy = 2*sigma + math.Abs(g.jumpNorth)/2
+	point.Epv = 2 * sigmaUp
+	return point
+}
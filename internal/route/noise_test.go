@@ -0,0 +1,134 @@
+package route
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNoiseValidate(t *testing.T) {
+	tests := []struct {
+		noise Noise
+		err   string
+	}{
+		{noise: Noise{}},
+		{noise: Noise{Model: NoiseModelMultipath, Sigma: 3, MultipathProbability: 1, MultipathJump: 50}},
+		{noise: Noise{Model: "pink", Sigma: 3}, err: `unsupported noise model "pink"`},
+		{noise: Noise{Model: NoiseModelWhite, Sigma: -1}, err: "can't be negative"},
+		{noise: Noise{Model: NoiseModelGaussMarkov, Sigma: 1, CorrelationTime: -60}, err: "can't be negative"},
+		{noise: Noise{Model: NoiseModelMultipath, Sigma: 1, MultipathProbability: 1.5}, err: "out of 0..1"},
+	}
+	for _, test := range tests {
+		err := test.noise.Validate()
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%+v: got error %v, want %q", test.noise, err, test.err)
+		}
+	}
+}
+
+// noiseSamples applies the noise to the same point count times and returns the errors east, north and up in meters
+func noiseSamples(noise Noise, count int) (east, north, up []float64, last Point) {
+	generator := newNoiseGenerator(noise)
+	origin := Point{Lat: startLat, Lon: startLon, Elevation: 500}
+	for range count {
+		last = generator.apply(origin, time.Second)
+		north = append(north, degreesToRadians(last.Lat-origin.Lat)*earthRadiusMeters)
+		east = append(east, degreesToRadians(last.Lon-origin.Lon)*earthRadiusMeters*math.Cos(degreesToRadians(last.Lat)))
+		up = append(up, last.Elevation-origin.Elevation)
+	}
+	return east, north, up, last
+}
+
+func deviation(values []float64) float64 {
+	var sum, squares float64
+	for _, value := range values {
+		sum += value
+		squares += value * value
+	}
+	mean := sum / float64(len(values))
+	return math.Sqrt(squares/float64(len(values)) - mean*mean)
+}
+
+// correlation is the lag-one autocorrelation of the values
+func correlation(values []float64) float64 {
+	var product, squares float64
+	for i, value := range values {
+		squares += value * value
+		if i > 0 {
+			product += value * values[i-1]
+		}
+	}
+	return product / squares
+}
+
+func TestNoiseModels(t *testing.T) {
+	const count = 20000
+	tests := []struct {
+		noise       Noise
+		correlation float64
+	}{
+		{Noise{Model: NoiseModelWhite, Sigma: 4, Seed: 1}, 0},
+		{Noise{Model: NoiseModelGaussMarkov, Sigma: 4, Seed: 1, CorrelationTime: 10}, math.Exp(-0.1)},
+	}
+	for _, test := range tests {
+		t.Run(test.noise.Model, func(t *testing.T) {
+			east, north, up, last := noiseSamples(test.noise, count)
+			for name, values := range map[string][]float64{"east": east, "north": north} {
+				if got := deviation(values); !near(got, test.noise.Sigma, 0.1*test.noise.Sigma) {
+					t.Errorf("got the %s deviation %f, want %f", name, got, test.noise.Sigma)
+				}
+				if got := correlation(values); !near(got, test.correlation, 0.05) {
+					t.Errorf("got the %s correlation %f, want %f", name, got, test.correlation)
+				}
+			}
+			if got := deviation(up); !near(got, test.noise.Sigma*verticalNoiseFactor, 0.1*test.noise.Sigma*verticalNoiseFactor) {
+				t.Errorf("got the vertical deviation %f, want %f", got, test.noise.Sigma*verticalNoiseFactor)
+			}
+			if last.Epx != 2*test.noise.Sigma || last.Epy != 2*test.noise.Sigma || last.Epv != 2*test.noise.Sigma*verticalNoiseFactor {
+				t.Errorf("got the error estimates %f, %f, %f", last.Epx, last.Epy, last.Epv)
+			}
+
+			// the seed repeats the noise
+			again, _, _, _ := noiseSamples(test.noise, 10)
+			for i := range again {
+				if again[i] != east[i] {
+					t.Fatalf("sample %d: got %f, then %f with the same seed", i, east[i], again[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMultipathNoise(t *testing.T) {
+	// the jumps always happen and dwarf the white noise, each lasts for the multipath duration in the same direction
+	noise := Noise{Model: NoiseModelMultipath, Sigma: 0.001, Seed: 1, MultipathProbability: 1, MultipathJump: 30}
+	east, north, _, last := noiseSamples(noise, 4*multipathDuration)
+	for i := range east {
+		if distance := math.Hypot(east[i], north[i]); !near(distance, noise.MultipathJump, 0.01) {
+			t.Errorf("sample %d: got %f m off, want the jump %f m", i, distance, noise.MultipathJump)
+		}
+		if i%multipathDuration > 0 && !near(east[i], east[i-1], 0.01) {
+			t.Errorf("sample %d: got the jump east %f, want %f of the previous sample", i, east[i], east[i-1])
+		}
+	}
+	if !near(last.Epx+last.Epy, 4*noise.Sigma+(math.Abs(east[len(east)-1])+math.Abs(north[len(north)-1]))/2, 0.01) {
+		t.Errorf("got the error estimates %f and %f, want half of the jump added", last.Epx, last.Epy)
+	}
+
+	// without the jumps multipath is white noise
+	noise = Noise{Model: NoiseModelMultipath, Sigma: 4, Seed: 1, MultipathProbability: 1e-9}
+	east, _, _, _ = noiseSamples(noise, 20000)
+	if got := deviation(east); !near(got, noise.Sigma, 0.1*noise.Sigma) {
+		t.Errorf("got the deviation %f without the jumps, want %f", got, noise.Sigma)
+	}
+}
+
+func TestNoiseDisabled(t *testing.T) {
+	point := Point{Lat: startLat, Lon: startLon, Elevation: 500}
+	for _, noise := range []Noise{{}, {Model: NoiseModelNone, Sigma: 5}, {Model: NoiseModelWhite}} {
+		if got := newNoiseGenerator(noise).apply(point, time.Second); got.Lat != point.Lat || got.Lon != point.Lon || got.Elevation != point.Elevation || got.Epx != 0 {
+			t.Errorf("%+v: got %+v, want the point as is", noise, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+const (
+	// OutageNoFix and Outage2D are the fix modes inside an outage, the same values as TPV/mode
+	OutageNoFix = 1
+	Outage2D    = 2
+)
+
+// Outage is a span of the route where the fix is lost or degraded to 2D, e.g. a tunnel or an urban canyon.
+// The span is either an area, the distance along the route in meters or the time since the route start in seconds.
+type Outage struct {
+	Mode         uint     `json:"mode"`
+	Area         []LatLon `json:"area,omitempty"`
+	FromDistance float64  `json:"fromDistance,omitempty"`
+	ToDistance   float64  `json:"toDistance,omitempty"`
+	FromTime     float64  `json:"fromTime,omitempty"`
+	ToTime       float64  `json:"toTime,omitempty"`
+}
+
+func (o Outage) Validate() error {
+	if o.Mode != OutageNoFix && o.Mode != Outage2D {
+		return fmt.Errorf("invalid outage mode %d, expected %d (no fix) or %d (2D)", o.Mode, OutageNoFix, Outage2D)
+	}
+	switch {
+	case len(o.Area) > 0:
+		if len(o.Area) < 3 {
+			return fmt.Errorf("outage area needs at least 3 points, got %d", len(o.Area))
+		}
+	case o.ToDistance > o.FromDistance, o.ToTime > o.FromTime:
+	default:
+		return fmt.Errorf("outage has neither an area nor a distance or time span")
+	}
+	return nil
+}
+
+// ParseOutage parses `distance:<from>-<to>[:<mode>]` with meters along the route or `time:<from>-<to>[:<mode>]`
+// with seconds since the route start, the mode is no fix by default
+func ParseOutage(value string) (Outage, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Outage{}, fmt.Errorf("invalid outage %q, expected distance|time:<from>-<to>[:<mode>]", value)
+	}
+	from, to, found := strings.Cut(parts[1], "-")
+	if !found {
+		return Outage{}, fmt.Errorf("invalid outage span %q, expected <from>-<to>", parts[1])
+	}
+	fromValue, err := strconv.ParseFloat(from, 64)
+	if err != nil {
+		return Outage{}, fmt.Errorf("invalid outage start %q: %w", from, err)
+	}
+	toValue, err := strconv.ParseFloat(to, 64)
+	if err != nil {
+		return Outage{}, fmt.Errorf("invalid outage end %q: %w", to, err)
+	}
+
+	outage := Outage{Mode: OutageNoFix}
+	if len(parts) == 3 {
+		mode, err := strconv.ParseUint(parts[2], 10, 32)
+		if err != nil {
+			return Outage{}, fmt.Errorf("invalid outage mode %q: %w", parts[2], err)
+		}
+		outage.Mode = uint(mode)
+	}
+	switch parts[0] {
+	case "distance":
+		outage.FromDistance, outage.ToDistance = fromValue, toValue
+	case "time":
+		outage.FromTime, outage.ToTime = fromValue, toValue
+	default:
+		return Outage{}, fmt.Errorf("invalid outage kind %q, expected distance or time", parts[0])
+	}
+
+	return outage, outage.Validate()
+}
+
+// contains checks if the point at the distance along the route and the time since its start is inside the outage
+func (o Outage) contains(point Point, distance, elapsed float64) bool {
+	switch {
+	case len(o.Area) > 0:
+		return insidePolygon(o.Area, point.Lat, point.Lon)
+	case o.ToDistance > o.FromDistance:
+		return distance >= o.FromDistance && distance < o.ToDistance
+	default:
+		return elapsed >= o.FromTime && elapsed < o.ToTime
+	}
+}
+
+// outageMode returns the worst fix mode of the outages containing the point, 0 when there are none
+func outageMode(outages []Outage, point Point, distance, elapsed float64) uint {
+	var mode uint
+	for _, outage := range outages {
+		if outage.contains(point, distance, elapsed) && (mode == 0 || outage.Mode < mode) {
+			mode = outage.Mode
+		}
+	}
+	return mode
+}
+
+// insidePolygon is the even-odd ray casting test, the polygon is small enough to treat lat/lon as planar
+func insidePolygon(polygon []LatLon, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Lat > lat) != (b.Lat > lat) && lon < (b.Lon-a.Lon)*(lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// FixMode returns the fix mode at the point, an outage can only make the receiver mode worse
+func (p Point) FixMode(receiverMode uint) uint {
+	if p.Mode != 0 && p.Mode < receiverMode {
+		return p.Mode
+	}
+	return receiverMode
+}
+
+// Sky returns the recorded satellites of the point or the ones of the model, degraded to the fix mode
+func (p Point) Sky(model sky.Model, mode uint) sky.View {
+	if p.Satellites != nil {
+		return sky.NewView(p.Satellites)
+	}
+	return sky.NewView(sky.Degrade(model.Satellites(p.Lat, p.Lon, p.Elevation, p.Time.UTC()), mode))
+}
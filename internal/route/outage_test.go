@@ -0,0 +1,117 @@
+package route
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+func TestParseOutage(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Outage
+		err   string
+	}{
+		{value: "distance:1000-1500", want: Outage{Mode: OutageNoFix, FromDistance: 1000, ToDistance: 1500}},
+		{value: "time:60-90.5:2", want: Outage{Mode: Outage2D, FromTime: 60, ToTime: 90.5}},
+		{value: "time:60-90:3", err: "invalid outage mode 3"},
+		{value: "time:90-60", err: "neither an area nor a distance or time span"},
+		{value: "area:1-2", err: `invalid outage kind "area"`},
+		{value: "distance:1000", err: "expected <from>-<to>"},
+		{value: "distance:start-1000", err: `invalid outage start "start"`},
+		{value: "distance:0-end", err: `invalid outage end "end"`},
+		{value: "distance:0-10:fix", err: `invalid outage mode "fix"`},
+		{value: "tunnel", err: "expected distance|time:<from>-<to>[:<mode>]"},
+	}
+	for _, test := range tests {
+		outage, err := ParseOutage(test.value)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil || outage.Mode != test.want.Mode || outage.FromDistance != test.want.FromDistance || outage.ToDistance != test.want.ToDistance ||
+			outage.FromTime != test.want.FromTime || outage.ToTime != test.want.ToTime {
+			t.Errorf("%s: got %+v and error %v, want %+v", test.value, outage, err, test.want)
+		}
+	}
+
+	if err := (Outage{Mode: OutageNoFix, Area: []LatLon{{1, 1}, {2, 2}}}).Validate(); err == nil || !strings.Contains(err.Error(), "at least 3 points") {
+		t.Errorf("got error %v, want the area too small", err)
+	}
+}
+
+func TestOutageMode(t *testing.T) {
+	square := []LatLon{{47, 8}, {47, 9}, {48, 9}, {48, 8}}
+	outages := []Outage{
+		{Mode: Outage2D, Area: square},
+		{Mode: OutageNoFix, FromDistance: 100, ToDistance: 200},
+		{Mode: Outage2D, FromTime: 10, ToTime: 20},
+	}
+	tests := []struct {
+		name     string
+		point    Point
+		distance float64
+		elapsed  float64
+		want     uint
+	}{
+		{"outside", Point{Lat: 46, Lon: 8.5}, 50, 5, 0},
+		{"inside the area", Point{Lat: 47.5, Lon: 8.5}, 50, 5, Outage2D},
+		{"the area and the distance span", Point{Lat: 47.5, Lon: 8.5}, 100, 5, OutageNoFix},
+		{"end of the distance span", Point{Lat: 46, Lon: 8.5}, 200, 5, 0},
+		{"time span", Point{Lat: 46, Lon: 8.5}, 50, 19.9, Outage2D},
+		{"the time and the distance span", Point{Lat: 46, Lon: 8.5}, 150, 10, OutageNoFix},
+	}
+	for _, test := range tests {
+		if got := outageMode(outages, test.point, test.distance, test.elapsed); got != test.want {
+			t.Errorf("%s: got mode %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFixMode(t *testing.T) {
+	for _, test := range []struct {
+		pointMode, receiverMode, want uint
+	}{
+		{0, 3, 3}, {2, 3, 2}, {1, 2, 1}, {3, 2, 2},
+	} {
+		if got := (Point{Mode: test.pointMode}).FixMode(test.receiverMode); got != test.want {
+			t.Errorf("point mode %d, receiver mode %d: got %d, want %d", test.pointMode, test.receiverMode, got, test.want)
+		}
+	}
+}
+
+// TestControllerOutages drives the route through the outages of the controller and of the route
+func TestControllerOutages(t *testing.T) {
+	controller := newTestController(line(90, []float64{0, 1000}, []float64{10, 10}))
+	if err := controller.SetOutages([]Outage{{Mode: Outage2D, FromTime: 2, ToTime: 4}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.SetRouteOutages([]Outage{{Mode: OutageNoFix, FromDistance: 25, ToDistance: 45}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.SetOutages([]Outage{{Mode: 0, FromTime: 2, ToTime: 4}}); err == nil {
+		t.Error("got no error for the outage without a mode")
+	}
+
+	satellites := []sky.Satellite{{PRN: 1, Elevation: 80, Used: true}, {PRN: 2, Elevation: 60, Used: true}, {PRN: 3, Elevation: 40, Used: true}, {PRN: 4, Elevation: 20, Used: true}}
+	want := []uint{0, 0, 2, 1, 1, 0, 0, 0}
+	for i, point := range drive(controller, len(want)) {
+		if point.Mode != want[i] {
+			t.Errorf("point %d: got mode %d, want %d", i, point.Mode, want[i])
+		}
+		if used := point.Sky(staticSky(satellites), point.FixMode(3)).Used(); point.Mode != 0 && used >= 4 {
+			t.Errorf("point %d: got %d satellites used in mode %d", i, used, point.Mode)
+		}
+	}
+}
+
+// staticSky is the model with the same satellites everywhere
+type staticSky []sky.Satellite
+
+func (s staticSky) Satellites(_, _, _ float64, _ time.Time) []sky.Satellite {
+	return s
+}
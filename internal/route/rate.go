@@ -0,0 +1,38 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinStepDelay is the fastest supported update rate, 20Hz
+const MinStepDelay = 50 * time.Millisecond
+
+// ParseRate accepts a frequency (`10Hz`, `10`) or a period (`100ms`) and returns the delay between the points
+func ParseRate(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var stepDelay time.Duration
+	if hz, found := strings.CutSuffix(strings.ToLower(value), "hz"); found || isNumber(value) {
+		frequency, err := strconv.ParseFloat(strings.TrimSpace(hz), 64)
+		if err != nil || frequency <= 0 {
+			return 0, fmt.Errorf("invalid rate %q", value)
+		}
+		stepDelay = time.Duration(float64(time.Second) / frequency)
+	} else {
+		var err error
+		if stepDelay, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("invalid rate %q, expected a frequency like 10Hz or a period like 100ms", value)
+		}
+	}
+	if stepDelay < MinStepDelay {
+		return 0, fmt.Errorf("rate %q is faster than the maximum of %.0fHz", value, 1/MinStepDelay.Seconds())
+	}
+	return stepDelay, nil
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
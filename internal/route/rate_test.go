@@ -0,0 +1,58 @@
+package route
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   string
+	}{
+		{value: "1", want: time.Second},
+		{value: "10Hz", want: 100 * time.Millisecond},
+		{value: " 5 hz ", want: 200 * time.Millisecond},
+		{value: "0.5", want: 2 * time.Second},
+		{value: "250ms", want: 250 * time.Millisecond},
+		{value: "2s", want: 2 * time.Second},
+		{value: "20Hz", want: MinStepDelay},
+		{value: "25Hz", err: "faster than the maximum of 20Hz"},
+		{value: "10ms", err: "faster than the maximum of 20Hz"},
+		{value: "0", err: `invalid rate "0"`},
+		{value: "-1Hz", err: `invalid rate "-1Hz"`},
+		{value: "fastHz", err: `invalid rate "fastHz"`},
+		{value: "fast", err: "expected a frequency like 10Hz or a period like 100ms"},
+	}
+	for _, test := range tests {
+		got, err := ParseRate(test.value)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, want %q", test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %v and error %v, want %v", test.value, got, err, test.want)
+		}
+	}
+}
+
+// TestControllerRate checks the route keeps its speed at any rate, the points are closer at the faster one
+func TestControllerRate(t *testing.T) {
+	points := line(90, []float64{0, 1000}, []float64{10, 10})
+	for _, stepDelay := range []time.Duration{time.Second, 100 * time.Millisecond, MinStepDelay} {
+		controller := newTestController(points)
+		controller.SetStepDelay(stepDelay)
+		driven := drive(controller, int(5*time.Second/stepDelay)+1)
+		last := driven[len(driven)-1]
+		if distance := calculateHaversineDistance(startLat, startLon, last.Lat, last.Lon); !near(distance, 50, 0.01) {
+			t.Errorf("%v: got %f m in 5 s, want 50 m", stepDelay, distance)
+		}
+		if elapsed := last.Time.Sub(driven[0].Time); elapsed != 5*time.Second {
+			t.Errorf("%v: got the points %v apart, want 5 s", stepDelay, elapsed)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package route
+
+// Receiver holds the properties of the simulated receiver which can't be derived from the route geometry
+type Receiver struct {
+	// GeoidSeparation is the height of the geoid (MSL) above the WGS84 ellipsoid in meters
+	GeoidSeparation float64 `json:"geoidSeparation,omitempty"`
+	// MagneticVariation is the magnetic declination in degrees, east is positive
+	MagneticVariation float64 `json:"magneticVariation,omitempty"`
+}
+
+func (r Receiver) apply(point Point) Point {
+	point.GeoidSeparation = r.GeoidSeparation
+	point.MagneticVariation = r.MagneticVariation
+	return point
+}
@@ -0,0 +1,83 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errNoRoute = errors.New("there is no route")
+
+// SeekTime moves the route to the time since its start, the path back of the reversed route included
+func (c *Controller) SeekTime(elapsed time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.route.Points) == 0 {
+		return errNoRoute
+	}
+	duration := time.Duration(c.timeline.duration() * float64(time.Second))
+	if elapsed < 0 || elapsed > duration {
+		return fmt.Errorf("time %s is out of the route duration %s", elapsed, duration)
+	}
+	c.seek(elapsed)
+	return nil
+}
+
+// SeekIndex moves the route to the point with the index
+func (c *Controller) SeekIndex(index int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.route.Points) == 0 {
+		return errNoRoute
+	}
+	if index < 0 || index >= len(c.route.Points) {
+		return fmt.Errorf("point index %d is out of 0..%d", index, len(c.route.Points)-1)
+	}
+	c.seek(time.Duration(c.timeline.times[index] * float64(time.Second)))
+	return nil
+}
+
+// SeekDistance moves the route to the distance in meters along it
+func (c *Controller) SeekDistance(distance float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.route.Points) == 0 {
+		return errNoRoute
+	}
+	if distance < 0 || distance > c.length {
+		return fmt.Errorf("distance %.0fm is out of the route length %.0fm", distance, c.length)
+	}
+	c.seek(time.Duration(c.timeline.timeAt(distance) * float64(time.Second)))
+	return nil
+}
+
+// SeekNearest moves the route to the point closest to the coordinates
+func (c *Controller) SeekNearest(lat, lon float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.route.Points) == 0 {
+		return errNoRoute
+	}
+	nearest := 0
+	nearestDistance := calculateHaversineDistance(lat, lon, c.route.Points[0].Lat, c.route.Points[0].Lon)
+	for i, point := range c.route.Points[1:] {
+		if distance := calculateHaversineDistance(lat, lon, point.Lat, point.Lon); distance < nearestDistance {
+			nearest, nearestDistance = i+1, distance
+		}
+	}
+	c.seek(time.Duration(c.timeline.times[nearest] * float64(time.Second)))
+	return nil
+}
+
+// Progress returns the time since the route start and the duration of the route path
+func (c *Controller) Progress() (elapsed, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.elapsed, time.Duration(c.timeline.duration() * float64(time.Second))
+}
+
+// seek must be called with the mu locked
+func (c *Controller) seek(elapsed time.Duration) {
+	c.elapsed = elapsed
+	c.log.Infof("Route: moved to %s since the start", elapsed.Round(time.Second))
+}
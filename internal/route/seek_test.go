@@ -0,0 +1,84 @@
+package route
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+)
+
+func TestSeek(t *testing.T) {
+	route := line(0, []float64{0, 400, 1000}, []float64{10, 10, 10})
+	nearLat, nearLon := calculateDestination(route[1].Lat, route[1].Lon, 90, 50)
+	tests := []struct {
+		name    string
+		seek    func(controller *Controller) error
+		elapsed time.Duration
+		err     bool
+	}{
+		{"time", func(c *Controller) error { return c.SeekTime(50 * time.Second) }, 50 * time.Second, false},
+		{"before the end", func(c *Controller) error { return c.SeekTime(99 * time.Second) }, 99 * time.Second, false},
+		{"negative time", func(c *Controller) error { return c.SeekTime(-time.Second) }, 0, true},
+		{"time after the end", func(c *Controller) error { return c.SeekTime(101 * time.Second) }, 0, true},
+		{"index", func(c *Controller) error { return c.SeekIndex(1) }, 40 * time.Second, false},
+		{"index out of range", func(c *Controller) error { return c.SeekIndex(3) }, 0, true},
+		{"distance", func(c *Controller) error { return c.SeekDistance(700) }, 70 * time.Second, false},
+		{"distance after the end", func(c *Controller) error { return c.SeekDistance(1001) }, 0, true},
+		{"nearest", func(c *Controller) error { return c.SeekNearest(nearLat, nearLon) }, 40 * time.Second, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controller := newTestController(route)
+			err := test.seek(controller)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			elapsed, duration := controller.Progress()
+			if elapsed.Round(time.Millisecond) != test.elapsed || duration.Round(time.Millisecond) != 100*time.Second {
+				t.Errorf("got progress %v of %v, want %v of 100s", elapsed, duration, test.elapsed)
+			}
+		})
+	}
+}
+
+func TestSeekPoint(t *testing.T) {
+	route := line(0, []float64{0, 400, 1000}, []float64{10, 10, 10})
+	controller := newTestController(route)
+	if err := controller.SeekDistance(700); err != nil {
+		t.Fatal(err)
+	}
+	point := drive(controller, 1)[0]
+	if distance := calculateHaversineDistance(route[0].Lat, route[0].Lon, point.Lat, point.Lon); !near(distance, 700, 0.01) {
+		t.Errorf("got the point %f m from the start, want 700", distance)
+	}
+
+	// the reversed route is seeked by time along the way back, by distance along the route
+	if err := controller.SetEnd(EndReverse); err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.SeekTime(150 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	point = drive(controller, 1)[0]
+	if distance := calculateHaversineDistance(route[0].Lat, route[0].Lon, point.Lat, point.Lon); !near(distance, 500, 0.01) || !near(point.Track, 180, 0.01) {
+		t.Errorf("got the point %f m from the start heading %f, want 500 m heading back", distance, point.Track)
+	}
+	if err := controller.SeekDistance(1500); err == nil {
+		t.Error("got no error for the distance on the way back")
+	}
+}
+
+func TestSeekWithoutRoute(t *testing.T) {
+	controller := NewController(context.Background(), time.Second, logger.NewStdoutLogger(logger.LevelFatal))
+	for name, err := range map[string]error{
+		"time":     controller.SeekTime(0),
+		"index":    controller.SeekIndex(0),
+		"distance": controller.SeekDistance(0),
+		"nearest":  controller.SeekNearest(startLat, startLon),
+	} {
+		if err != errNoRoute {
+			t.Errorf("%s: got error %v, want %v", name, err, errNoRoute)
+		}
+	}
+}
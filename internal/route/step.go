@@ -0,0 +1,112 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errFinished = errors.New("the route has ended")
+
+// Step switches the controller to the stepping mode, where the points are sent only when they are requested, and
+// requests the next count points. The stepping mode lasts until the route is run or paused.
+func (c *Controller) Step(count int) error {
+	if count < 1 {
+		return fmt.Errorf("step count %d must be positive", count)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.route.Points) == 0 {
+		return errNoRoute
+	}
+	if c.route.State == Finished {
+		return errFinished
+	}
+	if !c.stepping {
+		c.log.Infof("Route: stepping")
+	}
+	c.setStepping(true)
+	c.steps += count
+	c.route.State = Running
+	c.notifyChanged()
+	return nil
+}
+
+// SetStepping switches the stepping mode on or off without requesting any points, so the controller could start
+// in the stepping mode before the route is loaded
+func (c *Controller) SetStepping(stepping bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setStepping(stepping)
+	c.steps = 0
+	c.notifyChanged()
+}
+
+// setStepping holds the clock in the stepping mode, so the simulated time moves by the step with every point
+// and agrees with the distance driven. Must be called with the mu locked.
+func (c *Controller) setStepping(stepping bool) {
+	c.stepping = stepping
+	if stepping {
+		c.clock.Hold()
+	} else {
+		c.clock.Release()
+	}
+}
+
+func (c *Controller) Stepping() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stepping
+}
+
+// takeStep tells whether the next point could be sent, consuming a requested step in the stepping mode
+func (c *Controller) takeStep() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.stepping {
+		return true
+	}
+	if c.steps == 0 {
+		return false
+	}
+	c.steps--
+	if c.steps > 0 {
+		c.notifyChanged()
+	}
+	return true
+}
+
+// Run leaves the stepping mode and runs the route
+func (c *Controller) Run() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.run()
+}
+
+// Pause leaves the stepping mode and pauses the route
+func (c *Controller) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pause()
+}
+
+// run must be called with the mu locked
+func (c *Controller) run() {
+	if c.route.State == Finished {
+		return
+	}
+	c.setStepping(false)
+	c.steps = 0
+	c.route.State = Running
+	c.log.Infof("Route: running")
+}
+
+// pause must be called with the mu locked
+func (c *Controller) pause() {
+	if c.route.State == Finished {
+		return
+	}
+	c.setStepping(false)
+	c.steps = 0
+	c.route.State = Paused
+	c.log.Infof("Route: paused")
+}
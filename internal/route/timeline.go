@@ -0,0 +1,161 @@
+package route
+
+import (
+	"math"
+	"sort"
+)
+
+// minSegmentSpeed is used for the segments with zero speed at both ends, otherwise they would never end
+const minSegmentSpeed = 0.5
+
+// timeline resolves the route geometry and its speed profile, the speed at every vertex, into the time each
+// vertex is reached. The acceleration is constant between two vertices, so the speed changes linearly in time.
+// The segments between two recorded times take the recorded time, the distance is scaled to it.
+type timeline struct {
+	points    []Point
+	distances []float64
+	times     []float64
+	bearings  []float64
+	recorded  []bool
+}
+
+func newTimeline(points []Point) timeline {
+	t := timeline{
+		points:    points,
+		distances: make([]float64, len(points)),
+		times:     make([]float64, len(points)),
+		bearings:  make([]float64, len(points)),
+		recorded:  make([]bool, len(points)),
+	}
+	for i := 1; i < len(points); i++ {
+		prev, next := points[i-1], points[i]
+		distance := calculateHaversineDistance(prev.Lat, prev.Lon, next.Lat, next.Lon)
+		t.distances[i] = t.distances[i-1] + distance
+		duration := segmentDuration(distance, prev.Speed, next.Speed)
+		if prev.Timestamp != nil && next.Timestamp != nil && next.Timestamp.After(*prev.Timestamp) {
+			duration = next.Timestamp.Sub(*prev.Timestamp).Seconds()
+			t.recorded[i] = true
+		}
+		t.times[i] = t.times[i-1] + duration
+		t.bearings[i-1] = calculateInitialBearing(prev.Lat, prev.Lon, next.Lat, next.Lon)
+	}
+	if len(points) > 1 {
+		t.bearings[len(points)-1] = t.bearings[len(points)-2]
+	}
+	return t
+}
+
+func segmentDuration(distance, startSpeed, endSpeed float64) float64 {
+	if startSpeed+endSpeed <= 0 {
+		return distance / minSegmentSpeed
+	}
+	return 2 * distance / (startSpeed + endSpeed)
+}
+
+// duration is the time in seconds to drive the whole route
+func (t timeline) duration() float64 {
+	if len(t.times) == 0 {
+		return 0
+	}
+	return t.times[len(t.times)-1]
+}
+
+// sample interpolates the position, speed, track, elevation and climb at the time in seconds since the route
+// start, it also returns the distance driven so far. The recorded segments keep the recorded speed, track and fix.
+func (t timeline) sample(elapsed float64) (Point, float64) {
+	if len(t.points) == 0 {
+		return Point{}, 0
+	}
+	if elapsed <= 0 || len(t.points) == 1 {
+		point := t.points[0]
+		if len(t.points) == 1 || !t.recorded[1] {
+			point.Track = t.bearings[0]
+		}
+		return point, 0
+	}
+	if elapsed >= t.duration() {
+		last := len(t.points) - 1
+		point := t.points[last]
+		if !t.recorded[last] {
+			point.Track = t.bearings[last]
+		}
+		return point, t.distances[last]
+	}
+
+	// the segment which ends after the elapsed time
+	i := sort.SearchFloat64s(t.times, elapsed)
+	prev, next := t.points[i-1], t.points[i]
+	length := t.distances[i] - t.distances[i-1]
+	duration := t.times[i] - t.times[i-1]
+	tau := elapsed - t.times[i-1]
+
+	startSpeed, endSpeed := prev.Speed, next.Speed
+	if startSpeed+endSpeed <= 0 {
+		startSpeed, endSpeed = minSegmentSpeed, minSegmentSpeed
+	}
+	acceleration := (endSpeed - startSpeed) / duration
+	distance := min((startSpeed*tau+acceleration*tau*tau/2)*t.scale(i), length)
+	speed := startSpeed + acceleration*tau
+
+	lat, lon := calculateDestination(prev.Lat, prev.Lon, t.bearings[i-1], distance)
+	point := Point{
+		Lat:       lat,
+		Lon:       lon,
+		Speed:     speed,
+		Track:     t.bearings[i-1],
+		Elevation: prev.Elevation,
+	}
+	if t.recorded[i] {
+		point.Speed = prev.Speed + (next.Speed-prev.Speed)*tau/duration
+		point.Track = next.Track
+		point.Mode, point.Status, point.Satellites = prev.Mode, prev.Status, prev.Satellites
+		speed = point.Speed
+	}
+	if length > 0 {
+		slope := (next.Elevation - prev.Elevation) / length
+		point.Elevation += slope * distance
+		point.Climb = slope * speed
+	}
+	return point, t.distances[i-1] + distance
+}
+
+// scale is the ratio of the segment length to the distance driven in its time, 1 unless the time is recorded
+func (t timeline) scale(i int) float64 {
+	length := t.distances[i] - t.distances[i-1]
+	if !t.recorded[i] || length <= 0 {
+		return 1
+	}
+	startSpeed, endSpeed := t.points[i-1].Speed, t.points[i].Speed
+	if startSpeed+endSpeed <= 0 {
+		startSpeed, endSpeed = minSegmentSpeed, minSegmentSpeed
+	}
+	return length / ((startSpeed + endSpeed) / 2 * (t.times[i] - t.times[i-1]))
+}
+
+// timeAt is the time in seconds since the route start when the distance along the route is driven
+func (t timeline) timeAt(distance float64) float64 {
+	if len(t.points) < 2 || distance <= 0 {
+		return 0
+	}
+	if distance >= t.distances[len(t.distances)-1] {
+		return t.duration()
+	}
+
+	i := sort.SearchFloat64s(t.distances, distance)
+	prev, next := t.points[i-1], t.points[i]
+	duration := t.times[i] - t.times[i-1]
+	covered := distance - t.distances[i-1]
+
+	startSpeed, endSpeed := prev.Speed, next.Speed
+	if startSpeed+endSpeed <= 0 {
+		startSpeed, endSpeed = minSegmentSpeed, minSegmentSpeed
+	}
+	acceleration := (endSpeed - startSpeed) / duration
+	covered /= t.scale(i)
+	if math.Abs(acceleration) < 1e-9 {
+		return t.times[i-1] + min(covered/startSpeed, duration)
+	}
+	// the root of covered = startSpeed*tau + acceleration*tau²/2
+	tau := (math.Sqrt(max(startSpeed*startSpeed+2*acceleration*covered, 0)) - startSpeed) / acceleration
+	return t.times[i-1] + min(tau, duration)
+}
@@ -0,0 +1,150 @@
+package route
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/sky"
+)
+
+const (
+	startLat = 47.38
+	startLon = 8.44
+)
+
+// line returns the points along the bearing at the distances in meters with the speeds in m/s
+func line(bearing float64, distances, speeds []float64) []Point {
+	points := make([]Point, len(distances))
+	for i, distance := range distances {
+		lat, lon := calculateDestination(startLat, startLon, bearing, distance)
+		points[i] = Point{Lat: lat, Lon: lon, Speed: speeds[i]}
+	}
+	return points
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestTimelineSample(t *testing.T) {
+	tests := []struct {
+		name     string
+		points   []Point
+		duration float64
+		elapsed  float64
+		distance float64
+		speed    float64
+	}{
+		{"constant speed", line(90, []float64{0, 1000}, []float64{10, 10}), 100, 50, 500, 10},
+		{"acceleration from standstill", line(90, []float64{0, 1000}, []float64{0, 20}), 100, 50, 250, 10},
+		{"deceleration to standstill", line(90, []float64{0, 1000}, []float64{20, 0}), 100, 50, 750, 10},
+		{"standstill at both ends", line(90, []float64{0, 100}, []float64{0, 0}), 100 / minSegmentSpeed, 20, 20 * minSegmentSpeed, minSegmentSpeed},
+		{"second segment", line(0, []float64{0, 1000, 1500}, []float64{10, 10, 10}), 150, 120, 1200, 10},
+		{"before the start", line(90, []float64{0, 1000}, []float64{10, 10}), 100, -5, 0, 10},
+		{"after the end", line(90, []float64{0, 1000}, []float64{10, 10}), 100, 500, 1000, 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeline := newTimeline(test.points)
+			if !near(timeline.duration(), test.duration, 1e-6) {
+				t.Errorf("got duration %f, want %f", timeline.duration(), test.duration)
+			}
+			point, distance := timeline.sample(test.elapsed)
+			if !near(distance, test.distance, 1e-6) {
+				t.Errorf("got distance %f, want %f", distance, test.distance)
+			}
+			if !near(point.Speed, test.speed, 1e-6) {
+				t.Errorf("got speed %f, want %f", point.Speed, test.speed)
+			}
+			start := test.points[0]
+			if driven := calculateHaversineDistance(start.Lat, start.Lon, point.Lat, point.Lon); !near(driven, test.distance, 0.01) {
+				t.Errorf("got the point %f m from the start, want %f m", driven, test.distance)
+			}
+		})
+	}
+}
+
+func TestTimelineClimb(t *testing.T) {
+	points := line(90, []float64{0, 1000}, []float64{10, 10})
+	points[1].Elevation = 100
+	point, _ := newTimeline(points).sample(25)
+	if !near(point.Elevation, 25, 1e-6) || !near(point.Climb, 1, 1e-6) {
+		t.Errorf("got elevation %f and climb %f, want 25 and 1", point.Elevation, point.Climb)
+	}
+	if !near(point.Track, 90, 1e-6) {
+		t.Errorf("got track %f, want 90", point.Track)
+	}
+}
+
+// TestTimelineTimeAt checks timeAt is the inverse of sample
+func TestTimelineTimeAt(t *testing.T) {
+	recorded := line(45, []float64{0, 400, 1000}, []float64{4, 8, 2})
+	for i, seconds := range []int{0, 30, 100} {
+		timestamp := time.Date(2025, time.January, 1, 0, 0, seconds, 0, time.UTC)
+		recorded[i].Timestamp = &timestamp
+	}
+	routes := map[string][]Point{
+		"constant speed":     line(90, []float64{0, 1000, 1500}, []float64{10, 10, 10}),
+		"changing speed":     line(90, []float64{0, 300, 1000, 1200}, []float64{0, 15, 5, 0}),
+		"standstill at ends": line(90, []float64{0, 50}, []float64{0, 0}),
+		"recorded":           recorded,
+	}
+	for name, points := range routes {
+		t.Run(name, func(t *testing.T) {
+			timeline := newTimeline(points)
+			for fraction := 0.0; fraction <= 1; fraction += 0.05 {
+				elapsed := timeline.duration() * fraction
+				_, distance := timeline.sample(elapsed)
+				if got := timeline.timeAt(distance); !near(got, elapsed, 1e-3) {
+					t.Errorf("timeAt(%f) = %f, want %f", distance, got, elapsed)
+				}
+			}
+		})
+	}
+}
+
+func TestTimelineRecorded(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(100 * time.Second)
+	satellites := []sky.Satellite{{PRN: 5, GnssId: sky.GnssIdGPS, SvId: 5, Elevation: 40, Used: true}}
+	points := line(90, []float64{0, 1000}, []float64{5, 7})
+	points[0].Timestamp, points[0].Track, points[0].Mode, points[0].Status, points[0].Satellites = &start, 80, 2, 3, satellites
+	points[1].Timestamp, points[1].Track = &end, 100
+
+	timeline := newTimeline(points)
+	if timeline.duration() != 100 {
+		t.Fatalf("got duration %f, want the recorded 100", timeline.duration())
+	}
+	if !near(timeline.scale(1), 1000.0/600, 1e-9) {
+		t.Errorf("got scale %f, want %f", timeline.scale(1), 1000.0/600)
+	}
+
+	point, distance := timeline.sample(50)
+	// the recorded speed changes linearly in time, the distance is scaled to the recorded time
+	if !near(point.Speed, 6, 1e-9) || point.Track != 100 {
+		t.Errorf("got speed %f and track %f, want 6 and 100", point.Speed, point.Track)
+	}
+	if !near(distance, (5*50+0.02*50*50/2)*1000/600, 1e-6) {
+		t.Errorf("got distance %f", distance)
+	}
+	if point.Mode != 2 || point.Status != 3 || len(point.Satellites) != 1 {
+		t.Errorf("got mode %d, status %d and %d satellites, want the recorded fix", point.Mode, point.Status, len(point.Satellites))
+	}
+
+	if first, _ := timeline.sample(0); first.Track != 80 {
+		t.Errorf("got the first track %f, want the recorded 80", first.Track)
+	}
+	if last, distance := timeline.sample(100); last.Track != 100 || !near(distance, 1000, 1e-6) {
+		t.Errorf("got the last track %f at %f m, want the recorded 100 at 1000 m", last.Track, distance)
+	}
+
+	// the same point recorded twice keeps the recorded time without moving
+	stopped := []Point{points[0], points[0]}
+	later := start.Add(10 * time.Second)
+	stopped[1].Timestamp = &later
+	timeline = newTimeline(stopped)
+	if point, distance := timeline.sample(5); timeline.duration() != 10 || distance != 0 || point.Lat != points[0].Lat {
+		t.Errorf("got duration %f and distance %f, want 10 s at the same point", timeline.duration(), distance)
+	}
+}
@@ -0,0 +1,64 @@
+package route
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
+)
+
+const (
+	MinTimeScale = 0.1
+	MaxTimeScale = 100.0
+)
+
+func ValidateTimeScale(scale float64) error {
+	if scale < MinTimeScale || scale > MaxTimeScale {
+		return fmt.Errorf("time scale %g is out of %g..%g", scale, MinTimeScale, MaxTimeScale)
+	}
+	return nil
+}
+
+// SetClock replaces the simulated clock, the time scale is taken from it
+func (c *Controller) SetClock(simClock *clock.SimClock) error {
+	if err := ValidateTimeScale(simClock.Scale()); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = simClock
+	if c.stepping {
+		c.clock.Hold()
+	}
+	return nil
+}
+
+// simulatedStep is the simulated time between two points. Must be called with the mu locked.
+func (c *Controller) simulatedStep() time.Duration {
+	return time.Duration(float64(c.stepDelay) * c.clock.Scale())
+}
+
+// SetTimeScale changes how much faster than the wall clock the simulation runs
+func (c *Controller) SetTimeScale(scale float64) error {
+	if err := ValidateTimeScale(scale); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock.SetScale(scale)
+	c.log.Infof("Route: time scale set to %gx", scale)
+	return nil
+}
+
+// Now returns the simulated time
+func (c *Controller) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock.Now()
+}
+
+func (c *Controller) TimeScale() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock.Scale()
+}
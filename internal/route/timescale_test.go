@@ -0,0 +1,63 @@
+package route
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
+)
+
+func TestValidateTimeScale(t *testing.T) {
+	for _, scale := range []float64{MinTimeScale, 1, 2.5, MaxTimeScale} {
+		if err := ValidateTimeScale(scale); err != nil {
+			t.Errorf("%g: %v", scale, err)
+		}
+	}
+	for _, scale := range []float64{0, -1, 0.05, 101} {
+		if err := ValidateTimeScale(scale); err == nil {
+			t.Errorf("%g: got no error", scale)
+		}
+	}
+}
+
+// TestControllerTimeScale checks the scaled route moves the scaled distance and time every step
+func TestControllerTimeScale(t *testing.T) {
+	route := line(90, []float64{0, 10000}, []float64{10, 10})
+	start := route[0]
+	controller := newTestController(route)
+	if err := controller.SetTimeScale(10); err != nil {
+		t.Fatal(err)
+	}
+	if controller.TimeScale() != 10 {
+		t.Errorf("got time scale %g, want 10", controller.TimeScale())
+	}
+	points := drive(controller, 3)
+	if err := controller.SetTimeScale(0.5); err != nil {
+		t.Fatal(err)
+	}
+	points = append(points, drive(controller, 3)...)
+
+	distances := []float64{0, 100, 200, 300, 305, 310}
+	for i, point := range points {
+		if distance := calculateHaversineDistance(start.Lat, start.Lon, point.Lat, point.Lon); !near(distance, distances[i], 0.01) {
+			t.Errorf("point %d: got %f m from the start, want %f", i, distance, distances[i])
+		}
+		if i == 0 {
+			continue
+		}
+		want := 10 * time.Second
+		if i > 3 {
+			want = 500 * time.Millisecond
+		}
+		if step := point.Time.Sub(points[i-1].Time); step != want {
+			t.Errorf("point %d: got the time step %v, want %v", i, step, want)
+		}
+	}
+
+	if err := controller.SetTimeScale(MaxTimeScale * 2); err == nil || controller.TimeScale() != 0.5 {
+		t.Errorf("got error %v and time scale %g, want the time scale kept", err, controller.TimeScale())
+	}
+	if err := controller.SetClock(clock.NewVirtual(clock.Config{Scale: 1000})); err == nil {
+		t.Error("got no error for the clock out of the time scale")
+	}
+}
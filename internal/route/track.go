@@ -0,0 +1,114 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Track is the geometry of an imported route. The points keep the recorded time and elevation, Elevations tells
+// whether every point had one and Speeds whether the speed and track are recorded too. MaxSpeed in km/h is set
+// by the source when it has one.
+type Track struct {
+	Name       string
+	Points     []Point
+	Elevations bool
+	Speeds     bool
+	MaxSpeed   uint
+}
+
+// trackExtensions are the extensions of the files readTrack reads, the others are GeoJSON
+var trackExtensions = []string{".gpx", ".kml", ".kmz", ".nmea", ".nme", ".geojson"}
+
+// readTrack reads the points of a GPX, KML, KMZ or NMEA file or a GeoJSON feature, chosen by the file extension.
+// The feature selects a GeoJSON feature by its index or name.
+func readTrack(inputFile, feature string) (Track, error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		return Track{}, fmt.Errorf("failed to open input file %s: %w", inputFile, err)
+	}
+	defer input.Close()
+
+	var track Track
+	switch strings.ToLower(filepath.Ext(inputFile)) {
+	case ".gpx":
+		track, err = decodeGpx(input)
+	case ".kml":
+		track, err = decodeKml(input)
+	case ".kmz":
+		var info os.FileInfo
+		if info, err = input.Stat(); err == nil {
+			track, err = decodeKmz(input, info.Size())
+		}
+	case ".nmea", ".nme":
+		track, err = decodeNmea(input)
+	default:
+		track, err = decodeGeoJson(input, feature)
+	}
+	if err != nil {
+		return Track{}, fmt.Errorf("failed to decode input file %s: %w", inputFile, err)
+	}
+	return track, nil
+}
+
+// Export writes the route file in the format chosen by the output file extension, GPX, KML, KMZ or the route JSON
+func (c *Controller) Export(inputFile, outputFile string) error {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file %s: %w", inputFile, err)
+	}
+	defer input.Close()
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(outputFile)), ".")
+	if format != "" && !slices.Contains(routeFormats, format) {
+		return fmt.Errorf("unsupported route format %q of the output file %s, expected gpx, kml, kmz or json", format, outputFile)
+	}
+
+	var route Route
+	if err = json.NewDecoder(input).Decode(&route); err != nil {
+		return fmt.Errorf("failed to decode input file %s: %w", inputFile, err)
+	}
+
+	output, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open output file %s: %w", outputFile, err)
+	}
+	defer output.Close()
+
+	if err = c.WriteRoute(output, route, format); err != nil {
+		return fmt.Errorf("failed to encode route to output file %s: %w", outputFile, err)
+	}
+	c.log.Infof("Route: exported %d points to %s", len(route.Points), outputFile)
+	return nil
+}
+
+// routeFormats are the formats WriteRoute encodes, the route JSON without a format too
+var routeFormats = []string{"gpx", "kml", "kmz", "json"}
+
+// WriteRoute encodes the route in the format: gpx, kml, kmz or json. The times of the routes without the recorded
+// ones start at the current simulated time.
+func (c *Controller) WriteRoute(output io.Writer, route Route, format string) error {
+	switch format {
+	case "gpx":
+		return encodeGpx(output, route, c.Now().Truncate(time.Second))
+	case "kml":
+		return encodeKml(output, route, c.Now().Truncate(time.Second))
+	case "kmz":
+		return encodeKmz(output, route, c.Now().Truncate(time.Second))
+	case "json", "":
+		encoder := json.NewEncoder(output)
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(route)
+	}
+	return fmt.Errorf("unsupported route format %q, expected gpx, kml, kmz or json", format)
+}
+
+// trackSpeed is the speed in m/s to drive from the previous point to the point in their recorded time
+func trackSpeed(prev, point Point) float64 {
+	return calculateSpeedMetersPerSecond(prev.Lat, prev.Lon, point.Lat, point.Lon, point.Timestamp.Sub(*prev.Timestamp))
+}
@@ -0,0 +1,129 @@
+package route
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+const (
+	// AltitudeTerrain follows the terrain elevation
+	AltitudeTerrain = "terrain"
+	// AltitudeSurface keeps the altitude at the sea level, ignoring the terrain
+	AltitudeSurface = "surface"
+	// AltitudeFlight climbs from the start to the cruise altitude and descends to the end of the route
+	AltitudeFlight = "flight"
+)
+
+// Vehicle is a class of devices which drive the same route differently. MaxSpeed and Dynamics are the defaults
+// for the routes which don't set their own, Noise is stored in the route and TpvFields, when set, limit the
+// fields reported by the gpsd server.
+type Vehicle struct {
+	Name     string
+	MaxSpeed uint
+	Dynamics Dynamics
+	Noise    Noise
+	Altitude string
+	// CruiseAltitude is the flight altitude above the route start in meters
+	CruiseAltitude float64
+	// ClimbRate is the vertical speed of the climb and the descent in m/s
+	ClimbRate float64
+	TpvFields []string
+}
+
+var (
+	// handheld receivers report only the basic fix
+	tpvFieldsHandheld = []string{"status", "lat", "lon", "alt", "altHAE", "altMSL", "epx", "epy", "epv", "eph", "track", "speed"}
+	// marine receivers don't report the altitude
+	tpvFieldsMarine = []string{"status", "leapseconds", "ept", "lat", "lon", "epx", "epy", "eph", "track", "magtrack", "magvar", "speed", "eps"}
+)
+
+var Vehicles = map[string]Vehicle{
+	"pedestrian": {
+		Name:      "pedestrian",
+		MaxSpeed:  5,
+		Dynamics:  Dynamics{MaxAcceleration: 0.5, MaxDeceleration: 1, MaxLateralAcceleration: 1},
+		Noise:     Noise{Model: NoiseModelMultipath, Sigma: 3, Seed: 1, MultipathJump: 15},
+		Altitude:  AltitudeTerrain,
+		TpvFields: tpvFieldsHandheld,
+	},
+	"bicycle": {
+		Name:      "bicycle",
+		MaxSpeed:  20,
+		Dynamics:  Dynamics{MaxAcceleration: 1, MaxDeceleration: 2, MaxLateralAcceleration: 2},
+		Noise:     Noise{Model: NoiseModelWhite, Sigma: 2, Seed: 1},
+		Altitude:  AltitudeTerrain,
+		TpvFields: slices.Concat(tpvFieldsHandheld, []string{"climb"}),
+	},
+	"car": {
+		Name:     "car",
+		MaxSpeed: 110,
+		Dynamics: Dynamics{MaxAcceleration: 2.5, MaxDeceleration: 4, MaxLateralAcceleration: 3},
+		Noise:    Noise{Model: NoiseModelWhite, Sigma: 1.5, Seed: 1},
+		Altitude: AltitudeTerrain,
+	},
+	"truck": {
+		Name:     "truck",
+		MaxSpeed: 80,
+		Dynamics: Dynamics{MaxAcceleration: 1, MaxDeceleration: 2.5, MaxLateralAcceleration: 1.5},
+		Noise:    Noise{Model: NoiseModelWhite, Sigma: 1.5, Seed: 1},
+		Altitude: AltitudeTerrain,
+	},
+	"boat": {
+		Name:      "boat",
+		MaxSpeed:  30,
+		Dynamics:  Dynamics{MaxAcceleration: 0.3, MaxDeceleration: 0.5, MaxLateralAcceleration: 0.5},
+		Noise:     Noise{Model: NoiseModelGaussMarkov, Sigma: 2, Seed: 1},
+		Altitude:  AltitudeSurface,
+		TpvFields: tpvFieldsMarine,
+	},
+	// the aircraft flies straight between the route points, so there is no lateral limit
+	"aircraft": {
+		Name:           "aircraft",
+		MaxSpeed:       400,
+		Dynamics:       Dynamics{MaxAcceleration: 2, MaxDeceleration: 2},
+		Noise:          Noise{Model: NoiseModelWhite, Sigma: 1, Seed: 1},
+		Altitude:       AltitudeFlight,
+		CruiseAltitude: 3000,
+		ClimbRate:      7.5,
+	},
+}
+
+func VehicleNames() []string {
+	names := make([]string, 0, len(Vehicles))
+	for name := range Vehicles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func LookupVehicle(name string) (Vehicle, error) {
+	vehicle, ok := Vehicles[name]
+	if !ok {
+		return Vehicle{}, fmt.Errorf("unknown vehicle %q, expected one of %v", name, VehicleNames())
+	}
+	return vehicle, nil
+}
+
+// flightElevations sets the elevation of the points to the flight profile: the climb with the climb rate from
+// the start at the cruise speed, the cruise altitude and the descent to the end of the route
+func (v Vehicle) flightElevations(points []Point, cruiseSpeed float64) {
+	if len(points) < 2 || cruiseSpeed <= 0 {
+		return
+	}
+	gradient := v.ClimbRate / cruiseSpeed
+	start, end := points[0].Elevation, points[len(points)-1].Elevation
+
+	distances := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		distances[i] = distances[i-1] + calculateHaversineDistance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+	}
+	total := distances[len(distances)-1]
+	for i := range points {
+		climb := start + gradient*distances[i]
+		descent := end + gradient*(total-distances[i])
+		points[i].Elevation = math.Min(start+v.CruiseAltitude, math.Min(climb, descent))
+	}
+}
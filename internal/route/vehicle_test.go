@@ -0,0 +1,86 @@
+package route
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVehicles(t *testing.T) {
+	names := VehicleNames()
+	if !slices.IsSorted(names) || len(names) != len(Vehicles) {
+		t.Errorf("got names %v", names)
+	}
+	for _, name := range names {
+		vehicle, err := LookupVehicle(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vehicle.Name != name || vehicle.MaxSpeed == 0 {
+			t.Errorf("%s: got name %q and max speed %d", name, vehicle.Name, vehicle.MaxSpeed)
+		}
+		if err = vehicle.Dynamics.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if err = vehicle.Noise.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := LookupVehicle("rocket"); err == nil || !strings.Contains(err.Error(), `unknown vehicle "rocket"`) {
+		t.Errorf("got error %v, want the unknown vehicle", err)
+	}
+}
+
+func TestCreateRouteVehicle(t *testing.T) {
+	controller := newTestController(line(90, []float64{0, 1000}, []float64{10, 10}))
+	track := Track{Points: line(90, []float64{0, 2000, 4000}, []float64{0, 0, 0}), Elevations: true}
+
+	route := controller.createRoute(track, "truck", 0, Dynamics{})
+	truck := Vehicles["truck"]
+	if route.Vehicle != "truck" || route.MaxSpeed != truck.MaxSpeed {
+		t.Errorf("got vehicle %q with max speed %d, want the truck %d", route.Vehicle, route.MaxSpeed, truck.MaxSpeed)
+	}
+	if route.Dynamics == nil || *route.Dynamics != truck.Dynamics || route.Noise == nil || *route.Noise != truck.Noise {
+		t.Errorf("got dynamics %v and noise %v, want the truck ones", route.Dynamics, route.Noise)
+	}
+	for i, point := range route.Points {
+		if point.Speed > float64(truck.MaxSpeed)/3.6+1e-9 {
+			t.Errorf("point %d: got speed %f over the truck limit", i, point.Speed)
+		}
+	}
+
+	// the route settings replace the vehicle ones
+	dynamics := Dynamics{MaxAcceleration: 5}
+	route = controller.createRoute(track, "truck", 30, dynamics)
+	if route.MaxSpeed != 30 || *route.Dynamics != dynamics {
+		t.Errorf("got max speed %d and dynamics %v, want 30 and %v", route.MaxSpeed, *route.Dynamics, dynamics)
+	}
+
+	// the routes without the vehicle use the controller one
+	controller.SetVehicle(Vehicles["boat"])
+	route = controller.createRoute(track, "", 0, Dynamics{})
+	if route.Vehicle != "boat" || route.MaxSpeed != Vehicles["boat"].MaxSpeed {
+		t.Errorf("got vehicle %q with max speed %d, want the boat", route.Vehicle, route.MaxSpeed)
+	}
+
+	route = controller.createRoute(track, "rocket", 0, Dynamics{})
+	if route.Vehicle != "" || route.Dynamics != nil || route.Noise != nil {
+		t.Errorf("got vehicle %q, dynamics %v and noise %v for the unknown vehicle", route.Vehicle, route.Dynamics, route.Noise)
+	}
+}
+
+func TestFlightElevations(t *testing.T) {
+	aircraft := Vehicles["aircraft"]
+	cruiseSpeed := 100.0
+	points := line(0, []float64{0, 20000, 50000, 90000, 100000}, []float64{0, 0, 0, 0, 0})
+	points[0].Elevation, points[4].Elevation = 400, 500
+	aircraft.flightElevations(points, cruiseSpeed)
+
+	// 7.5 m of the climb per 100 m flown
+	want := []float64{400, 1900, 3400, 1250, 500}
+	for i, point := range points {
+		if !near(point.Elevation, want[i], 0.1) {
+			t.Errorf("point %d: got elevation %f, want %f", i, point.Elevation, want[i])
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package route
+
+// Virtual tells whether the controller runs on the virtual clock, producing the points as fast as the
+// listeners read them instead of every step delay
+func (c *Controller) Virtual() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock.Virtual()
+}
+
+// notifyChanged wakes up the virtual loop waiting for a listener or a route
+func (c *Controller) notifyChanged() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// ready tells whether there is a route and somebody to send its points to, the requested step is taken separately
+func (c *Controller) ready() bool {
+	if c.GetRouteSize() == 0 {
+		return false
+	}
+	c.listenersLock.Lock()
+	defer c.listenersLock.Unlock()
+	return len(c.listeners) > 0
+}
+
+// virtualLoop sends the points without any sleeps, the broadcast blocks until every listener has read the point
+// and the virtual clock advances by the step delay after it. The points are never sent to nobody, so the output
+// of a route is the same on every run. In the stepping mode only the requested points are sent.
+func (c *Controller) virtualLoop() {
+	for {
+		for !c.ready() || !c.takeStep() {
+			select {
+			case <-c.ctx.Done():
+				c.log.Infof("Route: the controller loop stopped")
+				return
+			case <-c.changed:
+			}
+		}
+		select {
+		case <-c.ctx.Done():
+			c.log.Infof("Route: the controller loop stopped")
+			return
+		default:
+		}
+
+		if !c.send() {
+			return
+		}
+		c.clock.Advance(c.StepDelay())
+	}
+}
@@ -0,0 +1,60 @@
+package route
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
+	"github.com/aokhrimenko/gpsd-simulator/internal/logger"
+)
+
+// virtualPoints runs the route on the virtual clock and reads the count points from the controller loop
+func virtualPoints(t *testing.T, count int) []Point {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	controller := NewController(ctx, time.Second, logger.NewStdoutLogger(logger.LevelFatal))
+	if err := controller.SetClock(clock.NewVirtual(clock.Config{})); err != nil {
+		t.Fatal(err)
+	}
+	controller.SetRoute(Route{
+		Points: line(90, []float64{0, 1000}, []float64{10, 10}),
+		Noise:  &Noise{Model: NoiseModelGaussMarkov, Sigma: 3, Seed: 7},
+	})
+	controller.Startup()
+	// nothing is sent before somebody listens
+	time.Sleep(10 * time.Millisecond)
+
+	listener, unsubscribe := controller.Subscribe()
+	defer unsubscribe()
+	points := make([]Point, 0, count)
+	for range count {
+		select {
+		case point := <-listener:
+			points = append(points, point)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d points, want %d", len(points), count)
+		}
+	}
+	return points
+}
+
+func TestVirtualLoop(t *testing.T) {
+	first := virtualPoints(t, 50)
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i, point := range first {
+		if want := start.Add(time.Duration(i) * time.Second); !point.Time.Equal(want) {
+			t.Fatalf("point %d: got time %v, want %v", i, point.Time, want)
+		}
+	}
+
+	// the seeded noise and the virtual clock make every run the same
+	second := virtualPoints(t, 50)
+	for i, point := range second {
+		want := first[i]
+		if point.Lat != want.Lat || point.Lon != want.Lon || point.Speed != want.Speed || point.Track != want.Track || !point.Time.Equal(want.Time) {
+			t.Errorf("point %d: got %+v, want %+v", i, point, want)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package sky
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
+	"github.com/aokhrimenko/gpsd-simulator/internal/wgs84"
+)
+
+const (
+	tleGravitationalConstant = 3.986004418e14
+	semRecordTokens          = 14
+)
+
+var (
+	tlePrnPattern    = regexp.MustCompile(`PRN\s*[GERC]?(\d+)`)
+	tleBeiDouPattern = regexp.MustCompile(`\(C(\d+)\)`)
+)
+
+// LoadAlmanac reads a YUMA or SEM GPS almanac, or a file with two- or three-line element sets (TLE) of
+// GPS, GLONASS, Galileo and BeiDou satellites. The format is detected by the file content. leapSeconds is the
+// GPS-UTC offset of the simulated clock, which could insert leap seconds of its own.
+func LoadAlmanac(path string, elevationMask float64, leapSeconds func(time.Time) int) (*OrbitModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read almanac %s: %w", path, err)
+	}
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	var orbits []orbit
+	switch {
+	case strings.Contains(content, "******** Week"):
+		orbits, err = parseYuma(content)
+	case isTle(content):
+		orbits, err = parseTle(content)
+	default:
+		orbits, err = parseSem(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse almanac %s: %w", path, err)
+	}
+	if len(orbits) == 0 {
+		return nil, fmt.Errorf("no satellites found in almanac %s", path)
+	}
+
+	return newOrbitModel(orbits, elevationMask, leapSeconds), nil
+}
+
+func newGpsOrbit(prn int, healthy bool, week int, toa, eccentricity, inclination, nodeRate, sqrtA, node, argPerigee, meanAnomaly float64) orbit {
+	semiMajorAxis := sqrtA * sqrtA
+	return orbit{
+		prn:           prn,
+		gnssId:        GnssIdGPS,
+		svId:          prn,
+		healthy:       healthy,
+		week:          week % weekRollover,
+		toa:           toa,
+		semiMajorAxis: semiMajorAxis,
+		eccentricity:  eccentricity,
+		inclination:   inclination,
+		// Ωk = Ω0 + (Ω̇ - Ω̇e)·tk - Ω̇e·toa
+		node:        node - wgs84.EarthRotationRate*toa,
+		nodeRate:    nodeRate - wgs84.EarthRotationRate,
+		argPerigee:  argPerigee,
+		meanAnomaly: meanAnomaly,
+		meanMotion:  math.Sqrt(wgs84.GravitationalConstant / (semiMajorAxis * semiMajorAxis * semiMajorAxis)),
+	}
+}
+
+// parseYuma reads records like
+//
+//	******** Week 256 almanac for PRN-01 ********
+//	ID:                         01
+//	Health:                     000
+//	Eccentricity:               0.1053333282E-001
+//	...
+func parseYuma(content string) ([]orbit, error) {
+	orbits := make([]orbit, 0, 32)
+	record := make(map[string]float64)
+
+	flush := func() error {
+		if len(record) == 0 {
+			return nil
+		}
+		for _, key := range []string{"id", "eccentricity", "time of applicability", "orbital inclination", "rate of right ascen", "sqrt(a)", "right ascen at week", "argument of perigee", "mean anom", "week"} {
+			if _, ok := record[key]; !ok {
+				return fmt.Errorf("YUMA record for PRN %.0f has no %q", record["id"], key)
+			}
+		}
+		orbits = append(orbits, newGpsOrbit(int(record["id"]), record["health"] == 0, int(record["week"]), record["time of applicability"],
+			record["eccentricity"], record["orbital inclination"], record["rate of right ascen"], record["sqrt(a)"],
+			record["right ascen at week"], record["argument of perigee"], record["mean anom"]))
+		record = make(map[string]float64)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "****") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		for _, known := range []string{"id", "health", "eccentricity", "time of applicability", "orbital inclination", "rate of right ascen", "sqrt(a)", "right ascen at week", "argument of perigee", "mean anom", "af0", "af1", "week"} {
+			if strings.HasPrefix(key, known) {
+				number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid YUMA value %q: %w", line, err)
+				}
+				record[known] = number
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return orbits, nil
+}
+
+// parseSem reads the SEM format: a header with the number of records and the title, the week and the time
+// of applicability, then records of PRN, SVN, URA, e, δi, Ω̇, √A, Ω0, ω, M0, af0, af1, health and
+// configuration, the angles are in semicircles
+func parseSem(content string) ([]orbit, error) {
+	lines := strings.SplitN(content, "\n", 3)
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("unknown almanac format")
+	}
+	header := strings.Fields(lines[0])
+	if len(header) == 0 {
+		return nil, fmt.Errorf("unknown almanac format")
+	}
+	count, err := strconv.Atoi(header[0])
+	if err != nil {
+		return nil, fmt.Errorf("unknown almanac format: invalid SEM records number %q", header[0])
+	}
+	weekToa := strings.Fields(lines[1])
+	if len(weekToa) < 2 {
+		return nil, fmt.Errorf("invalid SEM week and time of applicability %q", lines[1])
+	}
+	week, err := strconv.Atoi(weekToa[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid SEM week %q", weekToa[0])
+	}
+	toa, err := strconv.ParseFloat(weekToa[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SEM time of applicability %q", weekToa[1])
+	}
+
+	tokens := strings.Fields(lines[2])
+	if len(tokens) < count*semRecordTokens {
+		return nil, fmt.Errorf("SEM almanac has %d values, %d expected for %d records", len(tokens), count*semRecordTokens, count)
+	}
+
+	orbits := make([]orbit, 0, count)
+	for i := 0; i < count; i++ {
+		values := make([]float64, semRecordTokens)
+		for j := range values {
+			token := tokens[i*semRecordTokens+j]
+			if values[j], err = strconv.ParseFloat(token, 64); err != nil {
+				return nil, fmt.Errorf("invalid SEM value %q in record %d", token, i+1)
+			}
+		}
+		orbits = append(orbits, newGpsOrbit(int(values[0]), values[12] == 0, week, toa,
+			values[3], (0.3+values[4])*math.Pi, values[5]*math.Pi, values[6],
+			values[7]*math.Pi, values[8]*math.Pi, values[9]*math.Pi))
+	}
+
+	return orbits, nil
+}
+
+func isTle(content string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "1 ") && len(line) >= 64 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTle reads two-line element sets, optionally preceded by the name line which tells the constellation
+// and the PRN, e.g. "GPS BIIR-2  (PRN 13)", "GSAT0101 (PRN E11)", "BEIDOU-3 M1 (C19)" or "COSMOS 2425 (716K)".
+// The elements are propagated as a Keplerian orbit with J2 secular drift of the node and the perigee,
+// which is accurate enough for the sky view, but isn't SGP4.
+func parseTle(content string) ([]orbit, error) {
+	lines := make([]string, 0, 96)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), " \t"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	orbits := make([]orbit, 0, len(lines)/3)
+	svIds := make(map[int]int)
+	for i := 0; i+1 < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "1 ") || !strings.HasPrefix(lines[i+1], "2 ") {
+			continue
+		}
+		name := ""
+		if i > 0 && !strings.HasPrefix(lines[i-1], "2 ") {
+			name = strings.TrimSpace(strings.TrimPrefix(lines[i-1], "0 "))
+		}
+
+		gnssId, svId, known := classifyTle(name)
+		if !known {
+			i++
+			continue
+		}
+		if svId == 0 {
+			svIds[gnssId]++
+			svId = svIds[gnssId]
+		}
+
+		o, err := parseTleElements(lines[i], lines[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLE for %q: %w", name, err)
+		}
+		o.gnssId = gnssId
+		o.svId = svId
+		o.prn = prnFromSvId(gnssId, svId)
+		orbits = append(orbits, o)
+		i++
+	}
+
+	return orbits, nil
+}
+
+func classifyTle(name string) (gnssId int, svId int, known bool) {
+	upper := strings.ToUpper(name)
+	if match := tlePrnPattern.FindStringSubmatch(upper); match != nil {
+		svId, _ = strconv.Atoi(match[1])
+	}
+	switch {
+	case strings.Contains(upper, "BEIDOU"):
+		if match := tleBeiDouPattern.FindStringSubmatch(upper); match != nil {
+			svId, _ = strconv.Atoi(match[1])
+		}
+		return GnssIdBeiDou, svId, true
+	case strings.Contains(upper, "GSAT") || strings.Contains(upper, "GALILEO"):
+		return GnssIdGalileo, svId, true
+	case strings.Contains(upper, "GLONASS") || strings.Contains(upper, "COSMOS"):
+		return GnssIdGLONASS, svId, true
+	case strings.Contains(upper, "GPS") || strings.Contains(upper, "NAVSTAR") || name == "":
+		return GnssIdGPS, svId, true
+	default:
+		return 0, 0, false
+	}
+}
+
+func prnFromSvId(gnssId, svId int) int {
+	switch gnssId {
+	case GnssIdGLONASS:
+		return constellationGLONASS.firstPRN + svId - 1
+	case GnssIdGalileo:
+		return constellationGalileo.firstPRN + svId - 1
+	case GnssIdBeiDou:
+		return constellationBeiDou.firstPRN + svId - 1
+	default:
+		return svId
+	}
+}
+
+func parseTleElements(line1, line2 string) (orbit, error) {
+	if len(line1) < 32 || len(line2) < 63 {
+		return orbit{}, fmt.Errorf("element set lines are too short")
+	}
+	field := func(line string, from, to int) (float64, error) {
+		return strconv.ParseFloat(strings.TrimSpace(line[from-1:to]), 64)
+	}
+
+	epochYear, err := strconv.Atoi(strings.TrimSpace(line1[18:20]))
+	if err != nil {
+		return orbit{}, fmt.Errorf("invalid epoch year: %w", err)
+	}
+	epochDay, err := field(line1, 21, 32)
+	if err != nil {
+		return orbit{}, fmt.Errorf("invalid epoch day: %w", err)
+	}
+	if epochYear < 57 {
+		epochYear += 2000
+	} else {
+		epochYear += 1900
+	}
+	epoch := time.Date(epochYear, time.January, 1, 0, 0, 0, 0, time.UTC).
+		Add(time.Duration((epochDay - 1) * float64(24*time.Hour)))
+
+	var values [6]float64
+	for i, columns := range [][2]int{{9, 16}, {18, 25}, {27, 33}, {35, 42}, {44, 51}, {53, 63}} {
+		text := strings.TrimSpace(line2[columns[0]-1 : columns[1]])
+		if i == 2 {
+			// eccentricity has an implied leading decimal point
+			text = "0." + text
+		}
+		if values[i], err = strconv.ParseFloat(text, 64); err != nil {
+			return orbit{}, fmt.Errorf("invalid element %q: %w", text, err)
+		}
+	}
+	inclination := wgs84.DegreesToRadians(values[0])
+	rightAscension := wgs84.DegreesToRadians(values[1])
+	eccentricity := values[2]
+	meanMotion := values[5] * 2 * math.Pi / (24 * time.Hour).Seconds()
+	semiMajorAxis := math.Cbrt(tleGravitationalConstant / (meanMotion * meanMotion))
+
+	// J2 secular rates of the ascending node and the argument of perigee
+	semiLatusRectum := semiMajorAxis * (1 - eccentricity*eccentricity)
+	j2Factor := meanMotion * earthJ2 * math.Pow(wgs84.SemiMajorAxis/semiLatusRectum, 2)
+	nodeRate := -1.5 * j2Factor * math.Cos(inclination)
+	argPerigeeRate := 0.75 * j2Factor * (5*math.Cos(inclination)*math.Cos(inclination) - 1)
+
+	// the element sets are published with the real leap seconds, not the ones the simulated clock inserts
+	return orbit{
+		healthy:        true,
+		week:           -1,
+		epoch:          gpsSeconds(epoch, clock.LeapSeconds(epoch)),
+		semiMajorAxis:  semiMajorAxis,
+		eccentricity:   eccentricity,
+		inclination:    inclination,
+		node:           rightAscension - gmst(epoch),
+		nodeRate:       nodeRate - siderealRotation,
+		argPerigee:     wgs84.DegreesToRadians(values[3]),
+		argPerigeeRate: argPerigeeRate,
+		meanAnomaly:    wgs84.DegreesToRadians(values[4]),
+		meanMotion:     meanMotion,
+	}, nil
+}
@@ -0,0 +1,247 @@
+package sky
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/clock"
+	"github.com/aokhrimenko/gpsd-simulator/internal/wgs84"
+)
+
+const yumaAlmanac = `******** Week 256 almanac for PRN-01 ********
+ID:                         01
+Health:                     000
+Eccentricity:               0.1053333282E-001
+Time of Applicability(s):  405504.0000
+Orbital Inclination(rad):   0.9880308890
+Rate of Right Ascen(r/s):  -0.7634603130E-008
+SQRT(A)  (m 1/2):           5153.585449
+Right Ascen at Week(rad):  -0.2138358474E+001
+Argument of Perigee(rad):   0.925615430
+Mean Anom(rad):             0.1937706828E+001
+Af0(s):                     0.4863739014E-003
+Af1(s/s):                  -0.1091393642E-010
+week:                        256
+
+`
+
+// gpsTime returns the instant of the seconds of the full GPS week
+func gpsTime(week int, seconds float64) time.Time {
+	t := gpsEpoch.Add(time.Duration((float64(week)*secondsPerWeek + seconds) * float64(time.Second)))
+	return t.Add(-time.Duration(clock.LeapSeconds(t)) * time.Second)
+}
+
+// tle formats the element set in the fixed columns of the two-line format
+func tle(name string, epoch string, inclination, rightAscension float64, eccentricity int, argPerigee, meanAnomaly, revolutionsPerDay float64) string {
+	return fmt.Sprintf("%s\n1 00001U 00000A   %s  .00000000  00000-0  00000-0 0  9990\n2 00001 %8.4f %8.4f %07d %8.4f %8.4f %11.8f    00\n",
+		name, epoch, inclination, rightAscension, eccentricity, argPerigee, meanAnomaly, revolutionsPerDay)
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestOrbitPosition(t *testing.T) {
+	// circular polar orbit crossing the equator at the prime meridian at the time of applicability
+	const sqrtA = 5153.6
+	polar := newGpsOrbit(1, true, 0, 0, 0, math.Pi/2, 0, sqrtA, 0, 0, 0)
+	a := sqrtA * sqrtA
+	period := 2 * math.Pi / polar.meanMotion
+	halfPeriodNode := -wgs84.EarthRotationRate * period / 2
+
+	tests := []struct {
+		name    string
+		t       time.Time
+		x, y, z float64
+	}{
+		{"time of applicability", gpsTime(2048, 0), a, 0, 0},
+		{"next week rollover", gpsTime(3072, 0), a, 0, 0},
+		{"over the north pole", gpsTime(2048, period/4), 0, 0, a},
+		{"over the equator again", gpsTime(2048, period/2), -a * math.Cos(halfPeriodNode), -a * math.Sin(halfPeriodNode), 0},
+		{"over the south pole before", gpsTime(2047, secondsPerWeek-period/4), 0, 0, -a},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			x, y, z := polar.position(test.t, clock.LeapSeconds(test.t))
+			if !near(x, test.x, 1) || !near(y, test.y, 1) || !near(z, test.z, 1) {
+				t.Errorf("got %.0f, %.0f, %.0f, want %.0f, %.0f, %.0f", x, y, z, test.x, test.y, test.z)
+			}
+		})
+	}
+
+	model := newOrbitModel([]orbit{polar}, 10, clock.LeapSeconds)
+	if satellites := model.Satellites(0, 0, 0, gpsTime(2048, 0)); len(satellites) != 1 || satellites[0].Elevation != 90 || !satellites[0].Used {
+		t.Errorf("got %+v, want the satellite in the zenith", satellites)
+	}
+	if satellites := model.Satellites(0, 180, 0, gpsTime(2048, 0)); len(satellites) != 0 {
+		t.Errorf("got %+v from the other side of the Earth", satellites)
+	}
+
+	// the GPS time follows the offset of the simulated clock, a quarter of the period later the satellite is over the pole
+	shifted := newOrbitModel([]orbit{polar}, 10, func(t time.Time) int { return clock.LeapSeconds(t) + int(period/4) })
+	if satellites := shifted.Satellites(0, 0, 0, gpsTime(2048, 0)); len(satellites) != 0 {
+		t.Errorf("got %+v, want the satellite below the horizon", satellites)
+	}
+}
+
+func TestReferenceTime(t *testing.T) {
+	tests := []struct {
+		name string
+		week int
+		now  float64
+		want float64
+	}{
+		{"same week", 256, 2304*secondsPerWeek + 1000, 2304*secondsPerWeek + 405504},
+		{"week before", 255, 2304*secondsPerWeek + 1000, 2303*secondsPerWeek + 405504},
+		{"next week", 257, 2304*secondsPerWeek + 1000, 2305*secondsPerWeek + 405504},
+		{"across the rollover", 1023, 2048*secondsPerWeek + 1000, 2047*secondsPerWeek + 405504},
+	}
+	for _, test := range tests {
+		o := orbit{week: test.week, toa: 405504}
+		if got := o.referenceTime(test.now); got != test.want {
+			t.Errorf("%s: got week %.0f, want %.0f", test.name, got/secondsPerWeek, test.want/secondsPerWeek)
+		}
+	}
+	if got := (orbit{week: -1, epoch: 12345}).referenceTime(2304 * secondsPerWeek); got != 12345 {
+		t.Errorf("got %f, want the TLE epoch", got)
+	}
+}
+
+// TestYumaSem checks the same almanac in both formats gives the same orbit
+func TestYumaSem(t *testing.T) {
+	const (
+		eccentricity   = 0.1053333282e-001
+		inclination    = 0.9880308890
+		nodeRate       = -0.7634603130e-008
+		sqrtA          = 5153.585449
+		rightAscension = -0.2138358474e+001
+		argPerigee     = 0.925615430
+		meanAnomaly    = 0.1937706828e+001
+	)
+	sem := fmt.Sprintf("1 CURRENT.ALM\n256 405504\n\n1\n63\n0\n%.10E %.10E %.10E %.6f\n%.10E %.10E %.10E\n0.0 0.0\n0\n11\n",
+		eccentricity, inclination/math.Pi-0.3, nodeRate/math.Pi, sqrtA, rightAscension/math.Pi, argPerigee/math.Pi, meanAnomaly/math.Pi)
+
+	yuma, err := parseYuma(yumaAlmanac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	semOrbits, err := parseSem(sem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(yuma) != 1 || len(semOrbits) != 1 {
+		t.Fatalf("got %d YUMA and %d SEM orbits, want 1", len(yuma), len(semOrbits))
+	}
+	if o := yuma[0]; o.prn != 1 || !o.healthy || o.week != 256 || o.toa != 405504 || !near(o.semiMajorAxis, sqrtA*sqrtA, 1e-6) {
+		t.Errorf("got YUMA orbit %+v", o)
+	}
+
+	for _, at := range []time.Time{gpsTime(2304, 405504), gpsTime(2304, 0), gpsTime(2305, 86400)} {
+		x1, y1, z1 := yuma[0].position(at, clock.LeapSeconds(at))
+		x2, y2, z2 := semOrbits[0].position(at, clock.LeapSeconds(at))
+		if distance := math.Sqrt((x1-x2)*(x1-x2) + (y1-y2)*(y1-y2) + (z1-z2)*(z1-z2)); distance > 1 {
+			t.Errorf("%v: YUMA and SEM positions are %.1f m apart", at, distance)
+		}
+		radius := math.Sqrt(x1*x1 + y1*y1 + z1*z1)
+		if radius < sqrtA*sqrtA*(1-eccentricity) || radius > sqrtA*sqrtA*(1+eccentricity) {
+			t.Errorf("%v: got radius %.0f outside the orbit", at, radius)
+		}
+	}
+}
+
+func TestParseTle(t *testing.T) {
+	const epoch = "24001.50000000"
+	content := tle("GPS BIIR-2  (PRN 13)", epoch, 55, 100, 0, 0, 0, 2.00561883) +
+		tle("GSAT0101 (PRN E11)", epoch, 56, 100, 0, 0, 0, 1.70475) +
+		tle("IRIDIUM 106", epoch, 86.4, 100, 0, 0, 0, 14.34) +
+		tle("BEIDOU-3 M1 (C19)", epoch, 55, 100, 0, 0, 0, 1.86) +
+		tle("COSMOS 2425 (716K)", epoch, 64.8, 100, 0, 0, 0, 2.13)
+	if !isTle(content) {
+		t.Fatal("TLE not detected")
+	}
+	orbits, err := parseTle(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ prn, gnssId, svId int }{
+		{13, GnssIdGPS, 13},
+		{311, GnssIdGalileo, 11},
+		{419, GnssIdBeiDou, 19},
+		{65, GnssIdGLONASS, 1},
+	}
+	if len(orbits) != len(want) {
+		t.Fatalf("got %d orbits, want %d", len(orbits), len(want))
+	}
+	for i, w := range want {
+		if o := orbits[i]; o.prn != w.prn || o.gnssId != w.gnssId || o.svId != w.svId {
+			t.Errorf("orbit %d: got PRN %d, gnssid %d, svid %d, want %+v", i, o.prn, o.gnssId, o.svId, w)
+		}
+	}
+
+	gps := orbits[0]
+	if !near(gps.semiMajorAxis, 26560e3, 5e3) {
+		t.Errorf("got semi-major axis %.0f m, want about 26560 km", gps.semiMajorAxis)
+	}
+	// at the epoch the satellite is at the ascending node, its longitude is the right ascension less the sidereal time
+	at := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	x, y, z := gps.position(at, clock.LeapSeconds(at))
+	longitude := math.Remainder(math.Atan2(y, x)-(wgs84.DegreesToRadians(100)-gmst(at)), 2*math.Pi)
+	if !near(math.Sqrt(x*x+y*y+z*z), gps.semiMajorAxis, 1) || !near(z, 0, 1) || !near(longitude, 0, 1e-9) {
+		t.Errorf("got %.0f, %.0f, %.0f off the ascending node", x, y, z)
+	}
+}
+
+func TestGmst(t *testing.T) {
+	j2000 := time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+	for days, degrees := range []float64{280.46061837, 281.44626573629} {
+		got := gmst(j2000.AddDate(0, 0, days))
+		if want := wgs84.DegreesToRadians(degrees); !near(got, want, 1e-9) {
+			t.Errorf("%d days after J2000: got %f, want %f", days, got, want)
+		}
+	}
+}
+
+func TestLoadAlmanac(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		orbits  int
+		err     string
+	}{
+		{"yuma", yumaAlmanac + strings.ReplaceAll(yumaAlmanac, "PRN-01", "PRN-02"), 2, ""},
+		{"tle", tle("GPS BIIR-2  (PRN 13)", "24001.50000000", 55, 100, 0, 0, 0, 2.00561883), 1, ""},
+		{"yuma without a key", strings.ReplaceAll(yumaAlmanac, "SQRT(A)", "A"), 0, `has no "sqrt(a)"`},
+		{"short sem", "2 CURRENT.ALM\n256 405504\n1 63 0", 0, "values, 28 expected"},
+		{"short tle", "GPS\n1 24876U 97035A   24087.20833426  .00000032  00000+0  00000+0 0  9998\n2 24876  55.6153\n", 0, "too short"},
+		{"no satellites", "IRIDIUM 106\n" + strings.SplitN(tle("", "24001.50000000", 86.4, 100, 0, 0, 0, 14.34), "\n", 2)[1], 0, "no satellites"},
+		{"unknown", "hello", 0, "unknown almanac format"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name)
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			model, err := LoadAlmanac(path, 10, clock.LeapSeconds)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(model.orbits) != test.orbits {
+				t.Errorf("got %d orbits, want %d", len(model.orbits), test.orbits)
+			}
+		})
+	}
+}
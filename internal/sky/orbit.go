@@ -0,0 +1,133 @@
+package sky
+
+import (
+	"math"
+	"time"
+
+	"github.com/aokhrimenko/gpsd-simulator/internal/wgs84"
+)
+
+const (
+	secondsPerWeek = 7 * 24 * 60 * 60
+	// GPS weeks in the almanac files are transmitted modulo 1024
+	weekRollover = 1024
+
+	earthJ2          = 1.08262668e-3
+	siderealRotation = 7.2921158553e-5
+)
+
+var gpsEpoch = time.Date(1980, time.January, 6, 0, 0, 0, 0, time.UTC)
+
+// gpsSeconds converts the UTC time to the seconds since the GPS epoch with the GPS-UTC offset at that time
+func gpsSeconds(t time.Time, leapSeconds int) float64 {
+	return t.Sub(gpsEpoch).Seconds() + float64(leapSeconds)
+}
+
+// orbit holds Keplerian elements in the form the GPS almanac uses (IS-GPS-200, table 20-IV): the node
+// is the longitude of the ascending node in the Earth-fixed frame, so the propagated position is ECEF.
+type orbit struct {
+	prn     int
+	gnssId  int
+	svId    int
+	healthy bool
+
+	// week is the almanac week modulo 1024 and toa the seconds of that week, for TLE week is -1 and
+	// epoch is the absolute reference time in GPS seconds
+	week  int
+	toa   float64
+	epoch float64
+
+	semiMajorAxis  float64
+	eccentricity   float64
+	inclination    float64
+	node           float64
+	nodeRate       float64
+	argPerigee     float64
+	argPerigeeRate float64
+	meanAnomaly    float64
+	meanMotion     float64
+}
+
+// referenceTime resolves the almanac week to the one closest to the given time
+func (o orbit) referenceTime(now float64) float64 {
+	if o.week < 0 {
+		return o.epoch
+	}
+	currentWeek := int(math.Floor(now / secondsPerWeek))
+	fullWeek := currentWeek - ((currentWeek-o.week)%weekRollover+weekRollover)%weekRollover
+	if currentWeek-fullWeek > weekRollover/2 {
+		fullWeek += weekRollover
+	}
+	return float64(fullWeek)*secondsPerWeek + o.toa
+}
+
+// position propagates the orbit to the given time, with the GPS-UTC offset at it, and returns the ECEF
+// coordinates of the satellite
+func (o orbit) position(t time.Time, leapSeconds int) (x, y, z float64) {
+	now := gpsSeconds(t, leapSeconds)
+	tk := now - o.referenceTime(now)
+
+	meanAnomaly := o.meanAnomaly + o.meanMotion*tk
+	eccentricAnomaly := meanAnomaly
+	for i := 0; i < 10; i++ {
+		delta := (eccentricAnomaly - o.eccentricity*math.Sin(eccentricAnomaly) - meanAnomaly) / (1 - o.eccentricity*math.Cos(eccentricAnomaly))
+		eccentricAnomaly -= delta
+		if math.Abs(delta) < 1e-12 {
+			break
+		}
+	}
+
+	trueAnomaly := math.Atan2(math.Sqrt(1-o.eccentricity*o.eccentricity)*math.Sin(eccentricAnomaly), math.Cos(eccentricAnomaly)-o.eccentricity)
+	argumentOfLatitude := trueAnomaly + o.argPerigee + o.argPerigeeRate*tk
+	radius := o.semiMajorAxis * (1 - o.eccentricity*math.Cos(eccentricAnomaly))
+	node := o.node + o.nodeRate*tk
+
+	inPlaneX := radius * math.Cos(argumentOfLatitude)
+	inPlaneY := radius * math.Sin(argumentOfLatitude)
+
+	x = inPlaneX*math.Cos(node) - inPlaneY*math.Cos(o.inclination)*math.Sin(node)
+	y = inPlaneX*math.Sin(node) + inPlaneY*math.Cos(o.inclination)*math.Cos(node)
+	z = inPlaneY * math.Sin(o.inclination)
+	return x, y, z
+}
+
+// newOrbitModel computes the satellites positions from the orbits loaded with LoadAlmanac, leapSeconds is the
+// GPS-UTC offset of the simulated clock
+func newOrbitModel(orbits []orbit, elevationMask float64, leapSeconds func(time.Time) int) *OrbitModel {
+	return &OrbitModel{orbits: orbits, elevationMask: elevationMask, leapSeconds: leapSeconds}
+}
+
+type OrbitModel struct {
+	orbits        []orbit
+	elevationMask float64
+	leapSeconds   func(time.Time) int
+}
+
+func (m *OrbitModel) Satellites(lat, lon, alt float64, t time.Time) []Satellite {
+	satellites := make([]Satellite, 0, 16)
+	leapSeconds := m.leapSeconds(t)
+	for _, o := range m.orbits {
+		x, y, z := o.position(t, leapSeconds)
+		azimuth, elevation := wgs84.AzimuthElevation(lat, lon, alt, x, y, z)
+		if elevation <= 0 {
+			continue
+		}
+		satellites = append(satellites, Satellite{
+			PRN:       o.prn,
+			GnssId:    o.gnssId,
+			SvId:      o.svId,
+			Elevation: math.Round(elevation),
+			Azimuth:   math.Mod(math.Round(azimuth), 360),
+			SNR:       math.Round(22 + 25*math.Sin(degreesToRadians(elevation))),
+			Used:      o.healthy && elevation >= m.elevationMask,
+		})
+	}
+	return satellites
+}
+
+// gmst is the Greenwich mean sidereal time in radians (IAU 1982, sufficient for visibility calculations)
+func gmst(t time.Time) float64 {
+	julianDate := float64(t.UTC().UnixNano())/float64(24*time.Hour) + 2440587.5
+	degrees := 280.46061837 + 360.98564736629*(julianDate-2451545.0)
+	return math.Mod(wgs84.DegreesToRadians(degrees), 2*math.Pi)
+}
@@ -0,0 +1,121 @@
+package sky
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultGPS           = 9
+	DefaultGLONASS       = 0
+	DefaultGalileo       = 0
+	DefaultBeiDou        = 0
+	DefaultElevationMask = 10
+	DefaultSeed          = 1
+)
+
+type constellation struct {
+	gnssId   int
+	firstPRN int
+	size     int
+}
+
+var (
+	constellationGPS     = constellation{gnssId: GnssIdGPS, firstPRN: 1, size: 32}
+	constellationGLONASS = constellation{gnssId: GnssIdGLONASS, firstPRN: 65, size: 24}
+	constellationGalileo = constellation{gnssId: GnssIdGalileo, firstPRN: 301, size: 36}
+	constellationBeiDou  = constellation{gnssId: GnssIdBeiDou, firstPRN: 401, size: 37}
+)
+
+// SimulatedConfig sets how many satellites of each constellation are above the horizon at any time
+type SimulatedConfig struct {
+	GPS           uint
+	GLONASS       uint
+	Galileo       uint
+	BeiDou        uint
+	ElevationMask float64
+	Seed          int64
+}
+
+// slot is a place in the sky a satellite passes through: it rises, culminates at maxElevation
+// and sets, after that the next satellite of the same slot rises
+type slot struct {
+	constellation constellation
+	index         int
+	slots         int
+	passDuration  float64
+	phase         float64
+	azimuth       float64
+	azimuthRate   float64
+	maxElevation  float64
+	snrOffset     float64
+}
+
+// NewSimulatedModel creates a plausible but synthetic sky: the satellites follow smooth passes
+// derived from the seed and the time only, so the same seed always produces the same sky
+func NewSimulatedModel(config SimulatedConfig) *SimulatedModel {
+	random := rand.New(rand.NewSource(config.Seed))
+	model := &SimulatedModel{elevationMask: config.ElevationMask}
+
+	add := func(c constellation, count uint) {
+		if int(count) > c.size {
+			count = uint(c.size)
+		}
+		for i := 0; i < int(count); i++ {
+			model.slots = append(model.slots, slot{
+				constellation: c,
+				index:         i,
+				slots:         int(count),
+				passDuration:  (4 + 3*random.Float64()) * time.Hour.Seconds(),
+				phase:         random.Float64(),
+				azimuth:       360 * random.Float64(),
+				azimuthRate:   (random.Float64() - 0.5) * 0.02,
+				maxElevation:  25 + 63*random.Float64(),
+				snrOffset:     6 * (random.Float64() - 0.5),
+			})
+		}
+	}
+	add(constellationGPS, config.GPS)
+	add(constellationGLONASS, config.GLONASS)
+	add(constellationGalileo, config.Galileo)
+	add(constellationBeiDou, config.BeiDou)
+
+	return model
+}
+
+type SimulatedModel struct {
+	slots         []slot
+	elevationMask float64
+}
+
+func (m *SimulatedModel) Satellites(_, _, _ float64, t time.Time) []Satellite {
+	seconds := float64(t.UnixNano()) / float64(time.Second)
+	satellites := make([]Satellite, 0, len(m.slots))
+
+	for _, s := range m.slots {
+		progress := seconds/s.passDuration + s.phase
+		pass := int(math.Floor(progress))
+		elevation := s.maxElevation * math.Sin(math.Pi*(progress-float64(pass)))
+		azimuth := math.Mod(s.azimuth+s.azimuthRate*seconds+float64(pass)*137.5, 360)
+		if azimuth < 0 {
+			azimuth += 360
+		}
+
+		// Slots take the constellation numbers by turns, so two slots never show the same satellite
+		numbers := (s.constellation.size - s.index + s.slots - 1) / s.slots
+		svIndex := s.index + s.slots*(((pass%numbers)+numbers)%numbers)
+
+		satellites = append(satellites, Satellite{
+			PRN:       s.constellation.firstPRN + svIndex,
+			GnssId:    s.constellation.gnssId,
+			SvId:      svIndex + 1,
+			Elevation: math.Round(elevation),
+			Azimuth:   math.Mod(math.Round(azimuth), 360),
+			SNR:       math.Round(math.Max(0, 22+25*math.Sin(degreesToRadians(elevation))+s.snrOffset)),
+			Used:      elevation >= m.elevationMask,
+		})
+	}
+
+	return satellites
+}
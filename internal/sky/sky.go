@@ -0,0 +1,197 @@
+package sky
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// GNSS identifiers as used by gpsd in the SKY report
+const (
+	GnssIdGPS     = 0
+	GnssIdGalileo = 2
+	GnssIdBeiDou  = 3
+	GnssIdGLONASS = 6
+)
+
+// outageAttenuation is the signal loss in dB-Hz of the satellites dropped from a degraded fix
+const outageAttenuation = 20
+
+// Satellite describes a single satellite as seen from the receiver. PRN follows the gpsd numbering:
+// GPS 1-32, GLONASS 65-96, Galileo 301-336, BeiDou 401-437; SvId is the number within its constellation.
+type Satellite struct {
+	PRN       int     `json:"prn"`
+	GnssId    int     `json:"gnssid"`
+	SvId      int     `json:"svid"`
+	Elevation float64 `json:"el"`
+	Azimuth   float64 `json:"az"`
+	SNR       float64 `json:"ss"`
+	Used      bool    `json:"used"`
+}
+
+// NmeaId is the satellite number used in GSV/GSA sentences: GPS and GLONASS keep their PRN ranges,
+// the newer constellations are numbered from 1 under their own talker ID
+func (s Satellite) NmeaId() int {
+	switch s.GnssId {
+	case GnssIdGPS, GnssIdGLONASS:
+		return s.PRN
+	default:
+		return s.SvId
+	}
+}
+
+type Dop struct {
+	X, Y, V, T, H, P, G float64
+}
+
+type View struct {
+	Satellites []Satellite
+	Dop        Dop
+}
+
+func (v View) Used() int {
+	used := 0
+	for _, sat := range v.Satellites {
+		if sat.Used {
+			used++
+		}
+	}
+	return used
+}
+
+// Model provides the satellites visible from the receiver position at the given time
+type Model interface {
+	Satellites(lat, lon, alt float64, t time.Time) []Satellite
+}
+
+// Degrade removes satellites from the fix to match a degraded fix mode: no fix uses none of them and a 2D fix
+// only the three highest ones. The signal of the satellites removed from the fix is attenuated. With mode 0 or 3
+// the satellites are returned as is.
+func Degrade(satellites []Satellite, mode uint) []Satellite {
+	if mode == 0 || mode >= 3 {
+		return satellites
+	}
+
+	keep := 0
+	if mode == 2 {
+		keep = 3
+	}
+	degraded := make([]Satellite, len(satellites))
+	copy(degraded, satellites)
+	order := make([]int, len(degraded))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return degraded[order[a]].Elevation > degraded[order[b]].Elevation
+	})
+	for _, i := range order {
+		if degraded[i].Used && keep > 0 {
+			keep--
+			continue
+		}
+		degraded[i].Used = false
+		degraded[i].SNR = max(0, degraded[i].SNR-outageAttenuation)
+	}
+	return degraded
+}
+
+func NewView(satellites []Satellite) View {
+	return View{
+		Satellites: satellites,
+		Dop:        CalculateDop(satellites),
+	}
+}
+
+// CalculateDop computes dilution of precision from the line-of-sight geometry of the used satellites.
+// The geometry matrix rows are the unit vectors to the satellites in the local east-north-up frame plus
+// the receiver clock term, DOPs are the square roots of the diagonal of (GᵀG)⁻¹.
+// With three used satellites the altitude is held, as a 2D receiver does, and with less there is no solution
+// and all the values are zero.
+func CalculateDop(satellites []Satellite) Dop {
+	var normal [4][4]float64
+	used := 0
+	for _, sat := range satellites {
+		if !sat.Used {
+			continue
+		}
+		used++
+		el := degreesToRadians(sat.Elevation)
+		az := degreesToRadians(sat.Azimuth)
+		row := [4]float64{
+			math.Cos(el) * math.Sin(az),
+			math.Cos(el) * math.Cos(az),
+			math.Sin(el),
+			1,
+		}
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 4; j++ {
+				normal[i][j] += row[i] * row[j]
+			}
+		}
+	}
+	if used == 3 {
+		normal[2][2]++
+	} else if used < 3 {
+		return Dop{}
+	}
+
+	covariance, ok := invert4(normal)
+	if !ok {
+		return Dop{}
+	}
+
+	return Dop{
+		X: math.Sqrt(covariance[0][0]),
+		Y: math.Sqrt(covariance[1][1]),
+		V: math.Sqrt(covariance[2][2]),
+		T: math.Sqrt(covariance[3][3]),
+		H: math.Sqrt(covariance[0][0] + covariance[1][1]),
+		P: math.Sqrt(covariance[0][0] + covariance[1][1] + covariance[2][2]),
+		G: math.Sqrt(covariance[0][0] + covariance[1][1] + covariance[2][2] + covariance[3][3]),
+	}
+}
+
+// invert4 is Gauss-Jordan elimination with partial pivoting
+func invert4(m [4][4]float64) ([4][4]float64, bool) {
+	var inv [4][4]float64
+	for i := 0; i < 4; i++ {
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < 4; col++ {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return inv, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		factor := m[col][col]
+		for j := 0; j < 4; j++ {
+			m[col][j] /= factor
+			inv[col][j] /= factor
+		}
+		for row := 0; row < 4; row++ {
+			if row == col {
+				continue
+			}
+			factor = m[row][col]
+			for j := 0; j < 4; j++ {
+				m[row][j] -= factor * m[col][j]
+				inv[row][j] -= factor * inv[col][j]
+			}
+		}
+	}
+
+	return inv, true
+}
+
+func degreesToRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,115 @@
+package sky
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCalculateDop(t *testing.T) {
+	// the zenith and three satellites on the horizon 120° apart solve exactly, the DOPs are the row norms of the inverse
+	zenith := Satellite{PRN: 1, Elevation: 90, Used: true}
+	horizon := []Satellite{
+		{PRN: 2, Elevation: 0, Azimuth: 0, Used: true},
+		{PRN: 3, Elevation: 0, Azimuth: 120, Used: true},
+		{PRN: 4, Elevation: 0, Azimuth: 240, Used: true},
+	}
+	dop := CalculateDop(append([]Satellite{zenith, {PRN: 5, Elevation: 40, Azimuth: 10}}, horizon...))
+	want := Dop{X: math.Sqrt(2.0 / 3), Y: math.Sqrt(2.0 / 3), V: math.Sqrt(4.0 / 3), T: math.Sqrt(1.0 / 3), H: math.Sqrt(4.0 / 3), P: math.Sqrt(8.0 / 3), G: math.Sqrt(3)}
+	for _, value := range [][2]float64{{dop.X, want.X}, {dop.Y, want.Y}, {dop.V, want.V}, {dop.T, want.T}, {dop.H, want.H}, {dop.P, want.P}, {dop.G, want.G}} {
+		if !near(value[0], value[1], 1e-9) {
+			t.Errorf("got %+v, want %+v", dop, want)
+			break
+		}
+	}
+
+	// the altitude is held with three satellites, so the horizontal ones still solve
+	if dop := CalculateDop(horizon); dop.H == 0 || math.IsNaN(dop.H) || dop.P < dop.H {
+		t.Errorf("got %+v with three satellites, want the 2D solution", dop)
+	}
+	if dop := CalculateDop(horizon[:2]); dop != (Dop{}) {
+		t.Errorf("got %+v with two satellites, want none", dop)
+	}
+	// four satellites at the same place have no solution
+	same := slices.Repeat([]Satellite{{Elevation: 45, Azimuth: 90, Used: true}}, 4)
+	if dop := CalculateDop(same); dop != (Dop{}) {
+		t.Errorf("got %+v with the singular geometry, want none", dop)
+	}
+}
+
+func TestDegrade(t *testing.T) {
+	satellites := []Satellite{
+		{PRN: 1, Elevation: 20, SNR: 30, Used: true},
+		{PRN: 2, Elevation: 70, SNR: 45, Used: true},
+		{PRN: 3, Elevation: 5, SNR: 15},
+		{PRN: 4, Elevation: 50, SNR: 40, Used: true},
+		{PRN: 5, Elevation: 60, SNR: 10, Used: true},
+		{PRN: 6, Elevation: 30, SNR: 35, Used: true},
+	}
+	tests := []struct {
+		mode uint
+		used []int
+		snr  []float64
+	}{
+		{0, []int{1, 2, 4, 5, 6}, []float64{30, 45, 15, 40, 10, 35}},
+		{3, []int{1, 2, 4, 5, 6}, []float64{30, 45, 15, 40, 10, 35}},
+		{2, []int{2, 4, 5}, []float64{10, 45, 0, 40, 10, 15}},
+		{1, []int{}, []float64{10, 25, 0, 20, 0, 15}},
+	}
+	for _, test := range tests {
+		degraded := Degrade(satellites, test.mode)
+		used := make([]int, 0)
+		snr := make([]float64, 0)
+		for _, sat := range degraded {
+			if sat.Used {
+				used = append(used, sat.PRN)
+			}
+			snr = append(snr, sat.SNR)
+		}
+		if !slices.Equal(used, test.used) || !slices.Equal(snr, test.snr) {
+			t.Errorf("mode %d: got used %v and signals %v, want %v and %v", test.mode, used, snr, test.used, test.snr)
+		}
+	}
+	if !satellites[0].Used || satellites[0].SNR != 30 {
+		t.Errorf("got %+v, the satellites passed in changed", satellites[0])
+	}
+}
+
+func TestSimulatedModel(t *testing.T) {
+	config := SimulatedConfig{GPS: 9, GLONASS: 30, Galileo: 4, BeiDou: 2, ElevationMask: DefaultElevationMask, Seed: 7}
+	model := NewSimulatedModel(config)
+	ranges := map[int][2]int{GnssIdGPS: {1, 32}, GnssIdGLONASS: {65, 88}, GnssIdGalileo: {301, 336}, GnssIdBeiDou: {401, 437}}
+	start := time.Date(2025, time.June, 13, 17, 29, 0, 0, time.UTC)
+	for hour := 0; hour < 48; hour++ {
+		at := start.Add(time.Duration(hour) * time.Hour)
+		satellites := model.Satellites(47.38, 8.44, 500, at)
+		// GLONASS has 24 satellites only
+		if len(satellites) != 9+24+4+2 {
+			t.Fatalf("%v: got %d satellites, want 39", at, len(satellites))
+		}
+		seen := make(map[int]bool)
+		for _, sat := range satellites {
+			prns := ranges[sat.GnssId]
+			if sat.PRN < prns[0] || sat.PRN > prns[1] || sat.SvId != sat.PRN-prns[0]+1 || seen[sat.PRN] {
+				t.Fatalf("%v: got %+v out of its constellation or twice", at, sat)
+			}
+			seen[sat.PRN] = true
+			// the mask applies to the elevation before it's rounded
+			masked := sat.Used && sat.Elevation < config.ElevationMask || !sat.Used && sat.Elevation > config.ElevationMask
+			if sat.Elevation < 0 || sat.Elevation > 90 || sat.Azimuth < 0 || sat.Azimuth >= 360 || masked {
+				t.Fatalf("%v: got %+v", at, sat)
+			}
+		}
+	}
+
+	// the same seed always produces the same sky
+	at := start.Add(90 * time.Minute)
+	if !slices.Equal(model.Satellites(0, 0, 0, at), NewSimulatedModel(config).Satellites(0, 0, 0, at)) {
+		t.Error("got a different sky with the same seed")
+	}
+	config.Seed++
+	if slices.Equal(model.Satellites(0, 0, 0, at), NewSimulatedModel(config).Satellites(0, 0, 0, at)) {
+		t.Error("got the same sky with another seed")
+	}
+}
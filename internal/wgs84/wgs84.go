@@ -0,0 +1,76 @@
+package wgs84
+
+import "math"
+
+const (
+	SemiMajorAxis = 6378137.0
+	Flattening    = 1 / 298.257223563
+	// EccentricitySquared is e² = f(2-f)
+	EccentricitySquared = Flattening * (2 - Flattening)
+	// EarthRotationRate in rad/s as defined for GPS
+	EarthRotationRate = 7.2921151467e-5
+	// GravitationalConstant μ in m³/s² as defined for GPS
+	GravitationalConstant = 3.986005e14
+)
+
+func DegreesToRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func RadiansToDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// ToECEF converts geodetic latitude and longitude in degrees and height above the ellipsoid in meters
+// to Earth-centered Earth-fixed coordinates in meters
+func ToECEF(lat, lon, alt float64) (x, y, z float64) {
+	latRad := DegreesToRadians(lat)
+	lonRad := DegreesToRadians(lon)
+	sinLat := math.Sin(latRad)
+	cosLat := math.Cos(latRad)
+	primeVertical := SemiMajorAxis / math.Sqrt(1-EccentricitySquared*sinLat*sinLat)
+
+	x = (primeVertical + alt) * cosLat * math.Cos(lonRad)
+	y = (primeVertical + alt) * cosLat * math.Sin(lonRad)
+	z = (primeVertical*(1-EccentricitySquared) + alt) * sinLat
+	return x, y, z
+}
+
+// ToENU rotates the ECEF vector (dx, dy, dz) into the local east-north-up frame at lat, lon in degrees
+func ToENU(lat, lon, dx, dy, dz float64) (east, north, up float64) {
+	latRad := DegreesToRadians(lat)
+	lonRad := DegreesToRadians(lon)
+	sinLat, cosLat := math.Sin(latRad), math.Cos(latRad)
+	sinLon, cosLon := math.Sin(lonRad), math.Cos(lonRad)
+
+	east = -sinLon*dx + cosLon*dy
+	north = -sinLat*cosLon*dx - sinLat*sinLon*dy + cosLat*dz
+	up = cosLat*cosLon*dx + cosLat*sinLon*dy + sinLat*dz
+	return east, north, up
+}
+
+// FromENU rotates the local east-north-up vector at lat, lon in degrees back into the ECEF frame
+func FromENU(lat, lon, east, north, up float64) (dx, dy, dz float64) {
+	latRad := DegreesToRadians(lat)
+	lonRad := DegreesToRadians(lon)
+	sinLat, cosLat := math.Sin(latRad), math.Cos(latRad)
+	sinLon, cosLon := math.Sin(lonRad), math.Cos(lonRad)
+
+	dx = -sinLon*east - sinLat*cosLon*north + cosLat*cosLon*up
+	dy = cosLon*east - sinLat*sinLon*north + cosLat*sinLon*up
+	dz = cosLat*north + sinLat*up
+	return dx, dy, dz
+}
+
+// AzimuthElevation returns the direction in degrees from the observer at lat, lon, alt to the ECEF point x, y, z
+func AzimuthElevation(lat, lon, alt, x, y, z float64) (azimuth, elevation float64) {
+	ox, oy, oz := ToECEF(lat, lon, alt)
+	east, north, up := ToENU(lat, lon, x-ox, y-oy, z-oz)
+
+	azimuth = RadiansToDegrees(math.Atan2(east, north))
+	if azimuth < 0 {
+		azimuth += 360
+	}
+	elevation = RadiansToDegrees(math.Atan2(up, math.Hypot(east, north)))
+	return azimuth, elevation
+}
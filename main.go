@@ -18,7 +18,7 @@ func main() {
 		Short: "GPS simulator tool",
 		RunE:  runCmd.RunE,
 	}
-	root.AddCommand(runCmd, cmd.Import(Version))
+	root.AddCommand(runCmd, cmd.Import(Version), cmd.Export(Version))
 	runCmd.Flags().VisitAll(func(f *pflag.Flag) {
 		root.Flags().AddFlag(f)
 	})